@@ -0,0 +1,122 @@
+package bfsp_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"io"
+	"testing"
+
+	"github.com/BillysBigFileServer/bfsp-go"
+	"github.com/BillysBigFileServer/bfsp-go/bfsptest"
+	"github.com/google/uuid"
+)
+
+// compare a change with: go test -run '^$' -bench . -benchmem -count 10 > new.txt, run the same before it into old.txt, then benchstat old.txt new.txt
+const benchFileSize = 8 * bfsp.ChunkSize
+
+// benchData is half random and half repetitive, so compression has something to do without every chunk shrinking to nothing
+func benchData(b *testing.B, size int) []byte {
+	data := bytes.Repeat([]byte("bfsp benchmark "), size/15+1)[:size]
+	for i := 0; i < size; i += 2048 {
+		_, err := rand.Read(data[i:min(i+1024, size)])
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	return data
+}
+
+func benchContext(b *testing.B) context.Context {
+	masterKey := make(bfsp.MasterKey, 32)
+	_, err := rand.Read(masterKey)
+	if err != nil {
+		b.Fatal(err)
+	}
+	srv := bfsptest.NewServer()
+	return bfsp.ContextWithMasterKey(bfsp.ContextWithClient(context.Background(), srv.Client()), masterKey)
+}
+
+func BenchmarkCompressEncryptChunk(b *testing.B) {
+	masterKey := make(bfsp.MasterKey, 32)
+	_, err := rand.Read(masterKey)
+	if err != nil {
+		b.Fatal(err)
+	}
+	incompressible := make([]byte, bfsp.ChunkSize)
+	_, err = rand.Read(incompressible)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, bench := range []struct {
+		name string
+		data []byte
+	}{
+		{"Mixed", benchData(b, bfsp.ChunkSize)},
+		{"Incompressible", incompressible},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			fileMeta := &bfsp.FileMetadata{Id: uuid.NewString()}
+			chunkMeta := &bfsp.ChunkMetadata{
+				Id:    uuid.NewString(),
+				Size:  uint32(len(bench.data)),
+				Nonce: make([]byte, 24),
+			}
+
+			b.SetBytes(int64(len(bench.data)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, err := bfsp.CompressEncryptChunkWithDataKey(bench.data, chunkMeta, fileMeta, masterKey)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkUploadFile(b *testing.B) {
+	for _, bench := range []struct {
+		name string
+		opts []bfsp.UploadOption
+	}{
+		{"Fixed", nil},
+		{"ContentDefined", []bfsp.UploadOption{bfsp.WithContentDefinedChunking()}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			ctx := benchContext(b)
+			data := benchData(b, benchFileSize)
+
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, err := bfsp.UploadFile(ctx, &bfsp.FileInfo{Name: "bench", Reader: bytes.NewReader(data)}, 4, bench.opts...)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkDownloadFile(b *testing.B) {
+	ctx := benchContext(b)
+	data := benchData(b, benchFileSize)
+	res, err := bfsp.UploadFile(ctx, &bfsp.FileInfo{Name: "bench", Reader: bytes.NewReader(data)}, 4)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := bfsp.DownloadFile(ctx, res.FileMetadata, io.Discard, "")
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -85,6 +85,18 @@ type FileMetadata struct {
 	Directory        []string          `protobuf:"bytes,6,rep,name=directory,proto3" json:"directory,omitempty"`
 	CreateTime       int64             `protobuf:"varint,7,opt,name=create_time,json=createTime,proto3" json:"create_time,omitempty"`
 	ModificationTime int64             `protobuf:"varint,8,opt,name=modification_time,json=modificationTime,proto3" json:"modification_time,omitempty"`
+	// chunks are named by a keyed hash of their contents and encrypted with a key shared by all of the user's files, so identical chunks are only stored once
+	ContentAddressed bool `protobuf:"varint,9,opt,name=content_addressed,json=contentAddressed,proto3" json:"content_addressed,omitempty"`
+	// the plaintext size of each chunk, by indice
+	ChunkSizes map[uint64]uint32 `protobuf:"bytes,10,rep,name=chunk_sizes,json=chunkSizes,proto3" json:"chunk_sizes,omitempty" protobuf_key:"varint,1,opt,name=key,proto3" protobuf_val:"varint,2,opt,name=value,proto3"`
+	// the random key this file's chunks and metadata are encrypted with, itself encrypted with a key derived from the master key.
+	// files without one use a key derived from the master key and file id
+	WrappedDataKey []byte `protobuf:"bytes,11,opt,name=wrapped_data_key,json=wrappedDataKey,proto3" json:"wrapped_data_key,omitempty"`
+	// a blake3 hash tree over the indice, size and hash of every chunk, so a missing, extra or reordered chunk is noticed.
+	// empty for files uploaded before it existed
+	ChunkTreeHash []byte `protobuf:"bytes,12,opt,name=chunk_tree_hash,json=chunkTreeHash,proto3" json:"chunk_tree_hash,omitempty"`
+	// the chunks and metadata are padded, so their encrypted size doesn't give away how well they compressed
+	Padded bool `protobuf:"varint,13,opt,name=padded,proto3" json:"padded,omitempty"`
 }
 
 func (x *FileMetadata) Reset() {
@@ -175,6 +187,267 @@ func (x *FileMetadata) GetModificationTime() int64 {
 	return 0
 }
 
+func (x *FileMetadata) GetContentAddressed() bool {
+	if x != nil {
+		return x.ContentAddressed
+	}
+	return false
+}
+
+func (x *FileMetadata) GetChunkSizes() map[uint64]uint32 {
+	if x != nil {
+		return x.ChunkSizes
+	}
+	return nil
+}
+
+func (x *FileMetadata) GetWrappedDataKey() []byte {
+	if x != nil {
+		return x.WrappedDataKey
+	}
+	return nil
+}
+
+func (x *FileMetadata) GetChunkTreeHash() []byte {
+	if x != nil {
+		return x.ChunkTreeHash
+	}
+	return nil
+}
+
+func (x *FileMetadata) GetPadded() bool {
+	if x != nil {
+		return x.Padded
+	}
+	return false
+}
+
+// what EncryptedFileMetadata.metadata holds for files with a wrapped_data_key, after a magic prefix
+type FileMetadataEnvelope struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	WrappedDataKey []byte `protobuf:"bytes,1,opt,name=wrapped_data_key,json=wrappedDataKey,proto3" json:"wrapped_data_key,omitempty"`
+	Nonce          []byte `protobuf:"bytes,2,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	// the compressed FileMetadata, encrypted with the data key
+	EncryptedMetadata []byte `protobuf:"bytes,3,opt,name=encrypted_metadata,json=encryptedMetadata,proto3" json:"encrypted_metadata,omitempty"`
+}
+
+func (x *FileMetadataEnvelope) Reset() {
+	*x = FileMetadataEnvelope{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_bfsp_cli_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *FileMetadataEnvelope) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FileMetadataEnvelope) ProtoMessage() {}
+
+func (x *FileMetadataEnvelope) ProtoReflect() protoreflect.Message {
+	mi := &file_bfsp_cli_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FileMetadataEnvelope.ProtoReflect.Descriptor instead.
+func (*FileMetadataEnvelope) Descriptor() ([]byte, []int) {
+	return file_bfsp_cli_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *FileMetadataEnvelope) GetWrappedDataKey() []byte {
+	if x != nil {
+		return x.WrappedDataKey
+	}
+	return nil
+}
+
+func (x *FileMetadataEnvelope) GetNonce() []byte {
+	if x != nil {
+		return x.Nonce
+	}
+	return nil
+}
+
+func (x *FileMetadataEnvelope) GetEncryptedMetadata() []byte {
+	if x != nil {
+		return x.EncryptedMetadata
+	}
+	return nil
+}
+
+// how a key is derived from a password. It's generated per user and stored alongside their account, so stronger parameters can be rolled out later
+type KeyDerivation struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// 0 is the original derivation, which used the same salt for everyone and hashed the argon2 output with blake3
+	Version    uint32 `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	Algorithm  string `protobuf:"bytes,2,opt,name=algorithm,proto3" json:"algorithm,omitempty"`
+	Salt       []byte `protobuf:"bytes,3,opt,name=salt,proto3" json:"salt,omitempty"`
+	MCost      uint32 `protobuf:"varint,4,opt,name=m_cost,json=mCost,proto3" json:"m_cost,omitempty"`
+	TCost      uint32 `protobuf:"varint,5,opt,name=t_cost,json=tCost,proto3" json:"t_cost,omitempty"`
+	PCost      uint32 `protobuf:"varint,6,opt,name=p_cost,json=pCost,proto3" json:"p_cost,omitempty"`
+	OutputSize uint32 `protobuf:"varint,7,opt,name=output_size,json=outputSize,proto3" json:"output_size,omitempty"`
+}
+
+func (x *KeyDerivation) Reset() {
+	*x = KeyDerivation{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_bfsp_cli_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *KeyDerivation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*KeyDerivation) ProtoMessage() {}
+
+func (x *KeyDerivation) ProtoReflect() protoreflect.Message {
+	mi := &file_bfsp_cli_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use KeyDerivation.ProtoReflect.Descriptor instead.
+func (*KeyDerivation) Descriptor() ([]byte, []int) {
+	return file_bfsp_cli_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *KeyDerivation) GetVersion() uint32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *KeyDerivation) GetAlgorithm() string {
+	if x != nil {
+		return x.Algorithm
+	}
+	return ""
+}
+
+func (x *KeyDerivation) GetSalt() []byte {
+	if x != nil {
+		return x.Salt
+	}
+	return nil
+}
+
+func (x *KeyDerivation) GetMCost() uint32 {
+	if x != nil {
+		return x.MCost
+	}
+	return 0
+}
+
+func (x *KeyDerivation) GetTCost() uint32 {
+	if x != nil {
+		return x.TCost
+	}
+	return 0
+}
+
+func (x *KeyDerivation) GetPCost() uint32 {
+	if x != nil {
+		return x.PCost
+	}
+	return 0
+}
+
+func (x *KeyDerivation) GetOutputSize() uint32 {
+	if x != nil {
+		return x.OutputSize
+	}
+	return 0
+}
+
+// a master key encrypted with a key derived from the user's password, which the file server stores for them
+type EscrowedMasterKey struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Nonce        []byte         `protobuf:"bytes,2,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	EncryptedKey []byte         `protobuf:"bytes,3,opt,name=encrypted_key,json=encryptedKey,proto3" json:"encrypted_key,omitempty"`
+	Kdf          *KeyDerivation `protobuf:"bytes,4,opt,name=kdf,proto3" json:"kdf,omitempty"`
+}
+
+func (x *EscrowedMasterKey) Reset() {
+	*x = EscrowedMasterKey{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_bfsp_cli_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *EscrowedMasterKey) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EscrowedMasterKey) ProtoMessage() {}
+
+func (x *EscrowedMasterKey) ProtoReflect() protoreflect.Message {
+	mi := &file_bfsp_cli_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EscrowedMasterKey.ProtoReflect.Descriptor instead.
+func (*EscrowedMasterKey) Descriptor() ([]byte, []int) {
+	return file_bfsp_cli_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *EscrowedMasterKey) GetNonce() []byte {
+	if x != nil {
+		return x.Nonce
+	}
+	return nil
+}
+
+func (x *EscrowedMasterKey) GetEncryptedKey() []byte {
+	if x != nil {
+		return x.EncryptedKey
+	}
+	return nil
+}
+
+func (x *EscrowedMasterKey) GetKdf() *KeyDerivation {
+	if x != nil {
+		return x.Kdf
+	}
+	return nil
+}
+
 type ViewFileInfo struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -188,7 +461,7 @@ type ViewFileInfo struct {
 func (x *ViewFileInfo) Reset() {
 	*x = ViewFileInfo{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_bfsp_cli_proto_msgTypes[1]
+		mi := &file_bfsp_cli_proto_msgTypes[4]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -201,7 +474,7 @@ func (x *ViewFileInfo) String() string {
 func (*ViewFileInfo) ProtoMessage() {}
 
 func (x *ViewFileInfo) ProtoReflect() protoreflect.Message {
-	mi := &file_bfsp_cli_proto_msgTypes[1]
+	mi := &file_bfsp_cli_proto_msgTypes[4]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -214,7 +487,7 @@ func (x *ViewFileInfo) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ViewFileInfo.ProtoReflect.Descriptor instead.
 func (*ViewFileInfo) Descriptor() ([]byte, []int) {
-	return file_bfsp_cli_proto_rawDescGZIP(), []int{1}
+	return file_bfsp_cli_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *ViewFileInfo) GetId() string {
@@ -238,11 +511,359 @@ func (x *ViewFileInfo) GetFileEncKey() string {
 	return ""
 }
 
+// a share of several files, made with ShareFiles or ShareDirectory
+type ViewShareInfo struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// the id the share's manifest is stored under
+	ManifestId string `protobuf:"bytes,1,opt,name=manifest_id,json=manifestId,proto3" json:"manifest_id,omitempty"`
+	Token      string `protobuf:"bytes,2,opt,name=token,proto3" json:"token,omitempty"`
+	// the key the manifest is encrypted with
+	ShareKey string `protobuf:"bytes,3,opt,name=share_key,json=shareKey,proto3" json:"share_key,omitempty"`
+}
+
+func (x *ViewShareInfo) Reset() {
+	*x = ViewShareInfo{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_bfsp_cli_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ViewShareInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ViewShareInfo) ProtoMessage() {}
+
+func (x *ViewShareInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_bfsp_cli_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ViewShareInfo.ProtoReflect.Descriptor instead.
+func (*ViewShareInfo) Descriptor() ([]byte, []int) {
+	return file_bfsp_cli_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ViewShareInfo) GetManifestId() string {
+	if x != nil {
+		return x.ManifestId
+	}
+	return ""
+}
+
+func (x *ViewShareInfo) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+func (x *ViewShareInfo) GetShareKey() string {
+	if x != nil {
+		return x.ShareKey
+	}
+	return ""
+}
+
+// the files a share gives access to, and their keys. It's stored on the server encrypted with ViewShareInfo.share_key
+type ShareManifest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Files []*ShareManifest_SharedFile `protobuf:"bytes,1,rep,name=files,proto3" json:"files,omitempty"`
+	// the directory that was shared with ShareDirectory, empty for a set of files
+	Directory []string `protobuf:"bytes,2,rep,name=directory,proto3" json:"directory,omitempty"`
+}
+
+func (x *ShareManifest) Reset() {
+	*x = ShareManifest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_bfsp_cli_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ShareManifest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ShareManifest) ProtoMessage() {}
+
+func (x *ShareManifest) ProtoReflect() protoreflect.Message {
+	mi := &file_bfsp_cli_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ShareManifest.ProtoReflect.Descriptor instead.
+func (*ShareManifest) Descriptor() ([]byte, []int) {
+	return file_bfsp_cli_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ShareManifest) GetFiles() []*ShareManifest_SharedFile {
+	if x != nil {
+		return x.Files
+	}
+	return nil
+}
+
+func (x *ShareManifest) GetDirectory() []string {
+	if x != nil {
+		return x.Directory
+	}
+	return nil
+}
+
+// the shares a user has revoked, stored as file metadata with the id ShareRevocationsID. A self-hosted server rejects share tokens with any of revoked_share_ids, which are random so they don't give anything away.
+// everything else about the shares is in encrypted_revocations, which only the user can read
+type ShareRevocationList struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	RevokedShareIds      []string `protobuf:"bytes,1,rep,name=revoked_share_ids,json=revokedShareIds,proto3" json:"revoked_share_ids,omitempty"`
+	EncryptedRevocations []byte   `protobuf:"bytes,2,opt,name=encrypted_revocations,json=encryptedRevocations,proto3" json:"encrypted_revocations,omitempty"`
+}
+
+func (x *ShareRevocationList) Reset() {
+	*x = ShareRevocationList{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_bfsp_cli_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ShareRevocationList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ShareRevocationList) ProtoMessage() {}
+
+func (x *ShareRevocationList) ProtoReflect() protoreflect.Message {
+	mi := &file_bfsp_cli_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ShareRevocationList.ProtoReflect.Descriptor instead.
+func (*ShareRevocationList) Descriptor() ([]byte, []int) {
+	return file_bfsp_cli_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ShareRevocationList) GetRevokedShareIds() []string {
+	if x != nil {
+		return x.RevokedShareIds
+	}
+	return nil
+}
+
+func (x *ShareRevocationList) GetEncryptedRevocations() []byte {
+	if x != nil {
+		return x.EncryptedRevocations
+	}
+	return nil
+}
+
+// a share revoked with RevokeShare
+type ShareRevocation struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ShareId string `protobuf:"bytes,1,opt,name=share_id,json=shareId,proto3" json:"share_id,omitempty"`
+	FileId  string `protobuf:"bytes,2,opt,name=file_id,json=fileId,proto3" json:"file_id,omitempty"`
+	// unix seconds
+	RevokedAt int64 `protobuf:"varint,3,opt,name=revoked_at,json=revokedAt,proto3" json:"revoked_at,omitempty"`
+}
+
+func (x *ShareRevocation) Reset() {
+	*x = ShareRevocation{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_bfsp_cli_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ShareRevocation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ShareRevocation) ProtoMessage() {}
+
+func (x *ShareRevocation) ProtoReflect() protoreflect.Message {
+	mi := &file_bfsp_cli_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ShareRevocation.ProtoReflect.Descriptor instead.
+func (*ShareRevocation) Descriptor() ([]byte, []int) {
+	return file_bfsp_cli_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ShareRevocation) GetShareId() string {
+	if x != nil {
+		return x.ShareId
+	}
+	return ""
+}
+
+func (x *ShareRevocation) GetFileId() string {
+	if x != nil {
+		return x.FileId
+	}
+	return ""
+}
+
+func (x *ShareRevocation) GetRevokedAt() int64 {
+	if x != nil {
+		return x.RevokedAt
+	}
+	return 0
+}
+
+// the contents of ShareRevocationList.encrypted_revocations
+type ShareRevocations struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Revocations []*ShareRevocation `protobuf:"bytes,1,rep,name=revocations,proto3" json:"revocations,omitempty"`
+}
+
+func (x *ShareRevocations) Reset() {
+	*x = ShareRevocations{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_bfsp_cli_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ShareRevocations) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ShareRevocations) ProtoMessage() {}
+
+func (x *ShareRevocations) ProtoReflect() protoreflect.Message {
+	mi := &file_bfsp_cli_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ShareRevocations.ProtoReflect.Descriptor instead.
+func (*ShareRevocations) Descriptor() ([]byte, []int) {
+	return file_bfsp_cli_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ShareRevocations) GetRevocations() []*ShareRevocation {
+	if x != nil {
+		return x.Revocations
+	}
+	return nil
+}
+
+type ShareManifest_SharedFile struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id         string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	FileEncKey []byte `protobuf:"bytes,2,opt,name=file_enc_key,json=fileEncKey,proto3" json:"file_enc_key,omitempty"`
+}
+
+func (x *ShareManifest_SharedFile) Reset() {
+	*x = ShareManifest_SharedFile{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_bfsp_cli_proto_msgTypes[12]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ShareManifest_SharedFile) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ShareManifest_SharedFile) ProtoMessage() {}
+
+func (x *ShareManifest_SharedFile) ProtoReflect() protoreflect.Message {
+	mi := &file_bfsp_cli_proto_msgTypes[12]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ShareManifest_SharedFile.ProtoReflect.Descriptor instead.
+func (*ShareManifest_SharedFile) Descriptor() ([]byte, []int) {
+	return file_bfsp_cli_proto_rawDescGZIP(), []int{6, 0}
+}
+
+func (x *ShareManifest_SharedFile) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *ShareManifest_SharedFile) GetFileEncKey() []byte {
+	if x != nil {
+		return x.FileEncKey
+	}
+	return nil
+}
+
 var File_bfsp_cli_proto protoreflect.FileDescriptor
 
 var file_bfsp_cli_proto_rawDesc = []byte{
 	0x0a, 0x0e, 0x62, 0x66, 0x73, 0x70, 0x2e, 0x63, 0x6c, 0x69, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
-	0x12, 0x08, 0x62, 0x66, 0x73, 0x70, 0x2e, 0x63, 0x6c, 0x69, 0x22, 0xec, 0x02, 0x0a, 0x0c, 0x46,
+	0x12, 0x08, 0x62, 0x66, 0x73, 0x70, 0x2e, 0x63, 0x6c, 0x69, 0x22, 0x8b, 0x05, 0x0a, 0x0c, 0x46,
 	0x69, 0x6c, 0x65, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x12, 0x0e, 0x0a, 0x02, 0x69,
 	0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x3a, 0x0a, 0x06, 0x63,
 	0x68, 0x75, 0x6e, 0x6b, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x22, 0x2e, 0x62, 0x66,
@@ -261,21 +882,103 @@ var file_bfsp_cli_proto_rawDesc = []byte{
 	0x07, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0a, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x54, 0x69, 0x6d,
 	0x65, 0x12, 0x2b, 0x0a, 0x11, 0x6d, 0x6f, 0x64, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f,
 	0x6e, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x08, 0x20, 0x01, 0x28, 0x03, 0x52, 0x10, 0x6d, 0x6f,
-	0x64, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x54, 0x69, 0x6d, 0x65, 0x1a, 0x39,
+	0x64, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x54, 0x69, 0x6d, 0x65, 0x12, 0x2b,
+	0x0a, 0x11, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x5f, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73,
+	0x73, 0x65, 0x64, 0x18, 0x09, 0x20, 0x01, 0x28, 0x08, 0x52, 0x10, 0x63, 0x6f, 0x6e, 0x74, 0x65,
+	0x6e, 0x74, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x65, 0x64, 0x12, 0x47, 0x0a, 0x0b, 0x63,
+	0x68, 0x75, 0x6e, 0x6b, 0x5f, 0x73, 0x69, 0x7a, 0x65, 0x73, 0x18, 0x0a, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x26, 0x2e, 0x62, 0x66, 0x73, 0x70, 0x2e, 0x63, 0x6c, 0x69, 0x2e, 0x46, 0x69, 0x6c, 0x65,
+	0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x2e, 0x43, 0x68, 0x75, 0x6e, 0x6b, 0x53, 0x69,
+	0x7a, 0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x0a, 0x63, 0x68, 0x75, 0x6e, 0x6b, 0x53,
+	0x69, 0x7a, 0x65, 0x73, 0x12, 0x28, 0x0a, 0x10, 0x77, 0x72, 0x61, 0x70, 0x70, 0x65, 0x64, 0x5f,
+	0x64, 0x61, 0x74, 0x61, 0x5f, 0x6b, 0x65, 0x79, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0e,
+	0x77, 0x72, 0x61, 0x70, 0x70, 0x65, 0x64, 0x44, 0x61, 0x74, 0x61, 0x4b, 0x65, 0x79, 0x12, 0x26,
+	0x0a, 0x0f, 0x63, 0x68, 0x75, 0x6e, 0x6b, 0x5f, 0x74, 0x72, 0x65, 0x65, 0x5f, 0x68, 0x61, 0x73,
+	0x68, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0d, 0x63, 0x68, 0x75, 0x6e, 0x6b, 0x54, 0x72,
+	0x65, 0x65, 0x48, 0x61, 0x73, 0x68, 0x12, 0x16, 0x0a, 0x06, 0x70, 0x61, 0x64, 0x64, 0x65, 0x64,
+	0x18, 0x0d, 0x20, 0x01, 0x28, 0x08, 0x52, 0x06, 0x70, 0x61, 0x64, 0x64, 0x65, 0x64, 0x1a, 0x39,
 	0x0a, 0x0b, 0x43, 0x68, 0x75, 0x6e, 0x6b, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a,
 	0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12,
 	0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05,
-	0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x56, 0x0a, 0x0c, 0x56, 0x69, 0x65,
-	0x77, 0x46, 0x69, 0x6c, 0x65, 0x49, 0x6e, 0x66, 0x6f, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18,
-	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x74, 0x6f, 0x6b,
-	0x65, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x74, 0x6f, 0x6b, 0x65, 0x6e, 0x12,
-	0x20, 0x0a, 0x0c, 0x66, 0x69, 0x6c, 0x65, 0x5f, 0x65, 0x6e, 0x63, 0x5f, 0x6b, 0x65, 0x79, 0x18,
-	0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x66, 0x69, 0x6c, 0x65, 0x45, 0x6e, 0x63, 0x4b, 0x65,
-	0x79, 0x2a, 0x38, 0x0a, 0x08, 0x46, 0x69, 0x6c, 0x65, 0x54, 0x79, 0x70, 0x65, 0x12, 0x09, 0x0a,
-	0x05, 0x49, 0x4d, 0x41, 0x47, 0x45, 0x10, 0x00, 0x12, 0x08, 0x0a, 0x04, 0x54, 0x45, 0x58, 0x54,
-	0x10, 0x01, 0x12, 0x0b, 0x0a, 0x07, 0x55, 0x4e, 0x4b, 0x4e, 0x4f, 0x57, 0x4e, 0x10, 0x02, 0x12,
-	0x0a, 0x0a, 0x06, 0x42, 0x49, 0x4e, 0x41, 0x52, 0x59, 0x10, 0x03, 0x62, 0x06, 0x70, 0x72, 0x6f,
-	0x74, 0x6f, 0x33,
+	0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x1a, 0x3d, 0x0a, 0x0f, 0x43, 0x68, 0x75,
+	0x6e, 0x6b, 0x53, 0x69, 0x7a, 0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03,
+	0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14,
+	0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x76,
+	0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x85, 0x01, 0x0a, 0x14, 0x46, 0x69, 0x6c,
+	0x65, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x45, 0x6e, 0x76, 0x65, 0x6c, 0x6f, 0x70,
+	0x65, 0x12, 0x28, 0x0a, 0x10, 0x77, 0x72, 0x61, 0x70, 0x70, 0x65, 0x64, 0x5f, 0x64, 0x61, 0x74,
+	0x61, 0x5f, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0e, 0x77, 0x72, 0x61,
+	0x70, 0x70, 0x65, 0x64, 0x44, 0x61, 0x74, 0x61, 0x4b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x6e,
+	0x6f, 0x6e, 0x63, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x6e, 0x6f, 0x6e, 0x63,
+	0x65, 0x12, 0x2d, 0x0a, 0x12, 0x65, 0x6e, 0x63, 0x72, 0x79, 0x70, 0x74, 0x65, 0x64, 0x5f, 0x6d,
+	0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x11, 0x65,
+	0x6e, 0x63, 0x72, 0x79, 0x70, 0x74, 0x65, 0x64, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61,
+	0x22, 0xc1, 0x01, 0x0a, 0x0d, 0x4b, 0x65, 0x79, 0x44, 0x65, 0x72, 0x69, 0x76, 0x61, 0x74, 0x69,
+	0x6f, 0x6e, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x0d, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x1c, 0x0a, 0x09,
+	0x61, 0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74, 0x68, 0x6d, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x09, 0x61, 0x6c, 0x67, 0x6f, 0x72, 0x69, 0x74, 0x68, 0x6d, 0x12, 0x12, 0x0a, 0x04, 0x73, 0x61,
+	0x6c, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x73, 0x61, 0x6c, 0x74, 0x12, 0x15,
+	0x0a, 0x06, 0x6d, 0x5f, 0x63, 0x6f, 0x73, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05,
+	0x6d, 0x43, 0x6f, 0x73, 0x74, 0x12, 0x15, 0x0a, 0x06, 0x74, 0x5f, 0x63, 0x6f, 0x73, 0x74, 0x18,
+	0x05, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x74, 0x43, 0x6f, 0x73, 0x74, 0x12, 0x15, 0x0a, 0x06,
+	0x70, 0x5f, 0x63, 0x6f, 0x73, 0x74, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x70, 0x43,
+	0x6f, 0x73, 0x74, 0x12, 0x1f, 0x0a, 0x0b, 0x6f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x5f, 0x73, 0x69,
+	0x7a, 0x65, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0a, 0x6f, 0x75, 0x74, 0x70, 0x75, 0x74,
+	0x53, 0x69, 0x7a, 0x65, 0x22, 0x7f, 0x0a, 0x11, 0x45, 0x73, 0x63, 0x72, 0x6f, 0x77, 0x65, 0x64,
+	0x4d, 0x61, 0x73, 0x74, 0x65, 0x72, 0x4b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x6e, 0x6f, 0x6e,
+	0x63, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x6e, 0x6f, 0x6e, 0x63, 0x65, 0x12,
+	0x23, 0x0a, 0x0d, 0x65, 0x6e, 0x63, 0x72, 0x79, 0x70, 0x74, 0x65, 0x64, 0x5f, 0x6b, 0x65, 0x79,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0c, 0x65, 0x6e, 0x63, 0x72, 0x79, 0x70, 0x74, 0x65,
+	0x64, 0x4b, 0x65, 0x79, 0x12, 0x29, 0x0a, 0x03, 0x6b, 0x64, 0x66, 0x18, 0x04, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x17, 0x2e, 0x62, 0x66, 0x73, 0x70, 0x2e, 0x63, 0x6c, 0x69, 0x2e, 0x4b, 0x65, 0x79,
+	0x44, 0x65, 0x72, 0x69, 0x76, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x03, 0x6b, 0x64, 0x66, 0x4a,
+	0x04, 0x08, 0x01, 0x10, 0x02, 0x22, 0x56, 0x0a, 0x0c, 0x56, 0x69, 0x65, 0x77, 0x46, 0x69, 0x6c,
+	0x65, 0x49, 0x6e, 0x66, 0x6f, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x74, 0x6f, 0x6b, 0x65, 0x6e, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x74, 0x6f, 0x6b, 0x65, 0x6e, 0x12, 0x20, 0x0a, 0x0c, 0x66,
+	0x69, 0x6c, 0x65, 0x5f, 0x65, 0x6e, 0x63, 0x5f, 0x6b, 0x65, 0x79, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x0a, 0x66, 0x69, 0x6c, 0x65, 0x45, 0x6e, 0x63, 0x4b, 0x65, 0x79, 0x22, 0x63, 0x0a,
+	0x0d, 0x56, 0x69, 0x65, 0x77, 0x53, 0x68, 0x61, 0x72, 0x65, 0x49, 0x6e, 0x66, 0x6f, 0x12, 0x1f,
+	0x0a, 0x0b, 0x6d, 0x61, 0x6e, 0x69, 0x66, 0x65, 0x73, 0x74, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x0a, 0x6d, 0x61, 0x6e, 0x69, 0x66, 0x65, 0x73, 0x74, 0x49, 0x64, 0x12,
+	0x14, 0x0a, 0x05, 0x74, 0x6f, 0x6b, 0x65, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05,
+	0x74, 0x6f, 0x6b, 0x65, 0x6e, 0x12, 0x1b, 0x0a, 0x09, 0x73, 0x68, 0x61, 0x72, 0x65, 0x5f, 0x6b,
+	0x65, 0x79, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x73, 0x68, 0x61, 0x72, 0x65, 0x4b,
+	0x65, 0x79, 0x22, 0xa7, 0x01, 0x0a, 0x0d, 0x53, 0x68, 0x61, 0x72, 0x65, 0x4d, 0x61, 0x6e, 0x69,
+	0x66, 0x65, 0x73, 0x74, 0x12, 0x38, 0x0a, 0x05, 0x66, 0x69, 0x6c, 0x65, 0x73, 0x18, 0x01, 0x20,
+	0x03, 0x28, 0x0b, 0x32, 0x22, 0x2e, 0x62, 0x66, 0x73, 0x70, 0x2e, 0x63, 0x6c, 0x69, 0x2e, 0x53,
+	0x68, 0x61, 0x72, 0x65, 0x4d, 0x61, 0x6e, 0x69, 0x66, 0x65, 0x73, 0x74, 0x2e, 0x53, 0x68, 0x61,
+	0x72, 0x65, 0x64, 0x46, 0x69, 0x6c, 0x65, 0x52, 0x05, 0x66, 0x69, 0x6c, 0x65, 0x73, 0x12, 0x1c,
+	0x0a, 0x09, 0x64, 0x69, 0x72, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x79, 0x18, 0x02, 0x20, 0x03, 0x28,
+	0x09, 0x52, 0x09, 0x64, 0x69, 0x72, 0x65, 0x63, 0x74, 0x6f, 0x72, 0x79, 0x1a, 0x3e, 0x0a, 0x0a,
+	0x53, 0x68, 0x61, 0x72, 0x65, 0x64, 0x46, 0x69, 0x6c, 0x65, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x20, 0x0a, 0x0c, 0x66, 0x69,
+	0x6c, 0x65, 0x5f, 0x65, 0x6e, 0x63, 0x5f, 0x6b, 0x65, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c,
+	0x52, 0x0a, 0x66, 0x69, 0x6c, 0x65, 0x45, 0x6e, 0x63, 0x4b, 0x65, 0x79, 0x22, 0x76, 0x0a, 0x13,
+	0x53, 0x68, 0x61, 0x72, 0x65, 0x52, 0x65, 0x76, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x4c,
+	0x69, 0x73, 0x74, 0x12, 0x2a, 0x0a, 0x11, 0x72, 0x65, 0x76, 0x6f, 0x6b, 0x65, 0x64, 0x5f, 0x73,
+	0x68, 0x61, 0x72, 0x65, 0x5f, 0x69, 0x64, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0f,
+	0x72, 0x65, 0x76, 0x6f, 0x6b, 0x65, 0x64, 0x53, 0x68, 0x61, 0x72, 0x65, 0x49, 0x64, 0x73, 0x12,
+	0x33, 0x0a, 0x15, 0x65, 0x6e, 0x63, 0x72, 0x79, 0x70, 0x74, 0x65, 0x64, 0x5f, 0x72, 0x65, 0x76,
+	0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x14,
+	0x65, 0x6e, 0x63, 0x72, 0x79, 0x70, 0x74, 0x65, 0x64, 0x52, 0x65, 0x76, 0x6f, 0x63, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x73, 0x22, 0x64, 0x0a, 0x0f, 0x53, 0x68, 0x61, 0x72, 0x65, 0x52, 0x65, 0x76,
+	0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x19, 0x0a, 0x08, 0x73, 0x68, 0x61, 0x72, 0x65,
+	0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x73, 0x68, 0x61, 0x72, 0x65,
+	0x49, 0x64, 0x12, 0x17, 0x0a, 0x07, 0x66, 0x69, 0x6c, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x06, 0x66, 0x69, 0x6c, 0x65, 0x49, 0x64, 0x12, 0x1d, 0x0a, 0x0a, 0x72,
+	0x65, 0x76, 0x6f, 0x6b, 0x65, 0x64, 0x5f, 0x61, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52,
+	0x09, 0x72, 0x65, 0x76, 0x6f, 0x6b, 0x65, 0x64, 0x41, 0x74, 0x22, 0x4f, 0x0a, 0x10, 0x53, 0x68,
+	0x61, 0x72, 0x65, 0x52, 0x65, 0x76, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x3b,
+	0x0a, 0x0b, 0x72, 0x65, 0x76, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x01, 0x20,
+	0x03, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x62, 0x66, 0x73, 0x70, 0x2e, 0x63, 0x6c, 0x69, 0x2e, 0x53,
+	0x68, 0x61, 0x72, 0x65, 0x52, 0x65, 0x76, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x0b,
+	0x72, 0x65, 0x76, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x2a, 0x38, 0x0a, 0x08, 0x46,
+	0x69, 0x6c, 0x65, 0x54, 0x79, 0x70, 0x65, 0x12, 0x09, 0x0a, 0x05, 0x49, 0x4d, 0x41, 0x47, 0x45,
+	0x10, 0x00, 0x12, 0x08, 0x0a, 0x04, 0x54, 0x45, 0x58, 0x54, 0x10, 0x01, 0x12, 0x0b, 0x0a, 0x07,
+	0x55, 0x4e, 0x4b, 0x4e, 0x4f, 0x57, 0x4e, 0x10, 0x02, 0x12, 0x0a, 0x0a, 0x06, 0x42, 0x49, 0x4e,
+	0x41, 0x52, 0x59, 0x10, 0x03, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -291,21 +994,35 @@ func file_bfsp_cli_proto_rawDescGZIP() []byte {
 }
 
 var file_bfsp_cli_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_bfsp_cli_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_bfsp_cli_proto_msgTypes = make([]protoimpl.MessageInfo, 13)
 var file_bfsp_cli_proto_goTypes = []any{
-	(FileType)(0),        // 0: bfsp.cli.FileType
-	(*FileMetadata)(nil), // 1: bfsp.cli.FileMetadata
-	(*ViewFileInfo)(nil), // 2: bfsp.cli.ViewFileInfo
-	nil,                  // 3: bfsp.cli.FileMetadata.ChunksEntry
+	(FileType)(0),                    // 0: bfsp.cli.FileType
+	(*FileMetadata)(nil),             // 1: bfsp.cli.FileMetadata
+	(*FileMetadataEnvelope)(nil),     // 2: bfsp.cli.FileMetadataEnvelope
+	(*KeyDerivation)(nil),            // 3: bfsp.cli.KeyDerivation
+	(*EscrowedMasterKey)(nil),        // 4: bfsp.cli.EscrowedMasterKey
+	(*ViewFileInfo)(nil),             // 5: bfsp.cli.ViewFileInfo
+	(*ViewShareInfo)(nil),            // 6: bfsp.cli.ViewShareInfo
+	(*ShareManifest)(nil),            // 7: bfsp.cli.ShareManifest
+	(*ShareRevocationList)(nil),      // 8: bfsp.cli.ShareRevocationList
+	(*ShareRevocation)(nil),          // 9: bfsp.cli.ShareRevocation
+	(*ShareRevocations)(nil),         // 10: bfsp.cli.ShareRevocations
+	nil,                              // 11: bfsp.cli.FileMetadata.ChunksEntry
+	nil,                              // 12: bfsp.cli.FileMetadata.ChunkSizesEntry
+	(*ShareManifest_SharedFile)(nil), // 13: bfsp.cli.ShareManifest.SharedFile
 }
 var file_bfsp_cli_proto_depIdxs = []int32{
-	3, // 0: bfsp.cli.FileMetadata.chunks:type_name -> bfsp.cli.FileMetadata.ChunksEntry
-	0, // 1: bfsp.cli.FileMetadata.file_type:type_name -> bfsp.cli.FileType
-	2, // [2:2] is the sub-list for method output_type
-	2, // [2:2] is the sub-list for method input_type
-	2, // [2:2] is the sub-list for extension type_name
-	2, // [2:2] is the sub-list for extension extendee
-	0, // [0:2] is the sub-list for field type_name
+	11, // 0: bfsp.cli.FileMetadata.chunks:type_name -> bfsp.cli.FileMetadata.ChunksEntry
+	0,  // 1: bfsp.cli.FileMetadata.file_type:type_name -> bfsp.cli.FileType
+	12, // 2: bfsp.cli.FileMetadata.chunk_sizes:type_name -> bfsp.cli.FileMetadata.ChunkSizesEntry
+	3,  // 3: bfsp.cli.EscrowedMasterKey.kdf:type_name -> bfsp.cli.KeyDerivation
+	13, // 4: bfsp.cli.ShareManifest.files:type_name -> bfsp.cli.ShareManifest.SharedFile
+	9,  // 5: bfsp.cli.ShareRevocations.revocations:type_name -> bfsp.cli.ShareRevocation
+	6,  // [6:6] is the sub-list for method output_type
+	6,  // [6:6] is the sub-list for method input_type
+	6,  // [6:6] is the sub-list for extension type_name
+	6,  // [6:6] is the sub-list for extension extendee
+	0,  // [0:6] is the sub-list for field type_name
 }
 
 func init() { file_bfsp_cli_proto_init() }
@@ -327,6 +1044,42 @@ func file_bfsp_cli_proto_init() {
 			}
 		}
 		file_bfsp_cli_proto_msgTypes[1].Exporter = func(v any, i int) any {
+			switch v := v.(*FileMetadataEnvelope); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_bfsp_cli_proto_msgTypes[2].Exporter = func(v any, i int) any {
+			switch v := v.(*KeyDerivation); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_bfsp_cli_proto_msgTypes[3].Exporter = func(v any, i int) any {
+			switch v := v.(*EscrowedMasterKey); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_bfsp_cli_proto_msgTypes[4].Exporter = func(v any, i int) any {
 			switch v := v.(*ViewFileInfo); i {
 			case 0:
 				return &v.state
@@ -338,6 +1091,78 @@ func file_bfsp_cli_proto_init() {
 				return nil
 			}
 		}
+		file_bfsp_cli_proto_msgTypes[5].Exporter = func(v any, i int) any {
+			switch v := v.(*ViewShareInfo); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_bfsp_cli_proto_msgTypes[6].Exporter = func(v any, i int) any {
+			switch v := v.(*ShareManifest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_bfsp_cli_proto_msgTypes[7].Exporter = func(v any, i int) any {
+			switch v := v.(*ShareRevocationList); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_bfsp_cli_proto_msgTypes[8].Exporter = func(v any, i int) any {
+			switch v := v.(*ShareRevocation); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_bfsp_cli_proto_msgTypes[9].Exporter = func(v any, i int) any {
+			switch v := v.(*ShareRevocations); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_bfsp_cli_proto_msgTypes[12].Exporter = func(v any, i int) any {
+			switch v := v.(*ShareManifest_SharedFile); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
@@ -345,7 +1170,7 @@ func file_bfsp_cli_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_bfsp_cli_proto_rawDesc,
 			NumEnums:      1,
-			NumMessages:   3,
+			NumMessages:   13,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -2,13 +2,14 @@ package bfsp
 
 import (
 	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
 
 	"github.com/google/uuid"
-	"github.com/klauspost/compress/zstd"
 
 	"golang.org/x/crypto/chacha20poly1305"
 	"google.golang.org/protobuf/proto"
@@ -16,6 +17,40 @@ import (
 )
 
 func ListFileMetadata(cli FileServerClient, ids []string, masterKey MasterKey) (map[string]*FileMetadata, error) {
+	encFileMetas, err := listEncryptedFileMetadata(cli, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	fileMetas := map[string]*FileMetadata{}
+	for fileId, metaInfo := range encFileMetas {
+		fileMeta, err := decryptFileMetadata(metaInfo, masterKey)
+		if err != nil {
+			return fileMetas, err
+		}
+		fileMetas[fileId] = fileMeta
+	}
+
+	return fileMetas, nil
+}
+
+// listEncryptedFileMetadata lists file metadata without decrypting it, for when it might not all be encrypted with the same master key.
+// what's stored as file metadata but isn't a file's, like the share revocation list, is left out
+func listEncryptedFileMetadata(cli FileServerClient, ids []string) (map[string]*EncryptedFileMetadata, error) {
+	encFileMetas, err := listStoredMetadata(cli, ids)
+	if err != nil {
+		return nil, err
+	}
+	for id, encFileMeta := range encFileMetas {
+		if !isFileMetadata(encFileMeta) {
+			delete(encFileMetas, id)
+		}
+	}
+	return encFileMetas, nil
+}
+
+// listStoredMetadata lists everything stored as file metadata with ids, or all of it if ids is empty. Ids that don't exist are left out
+func listStoredMetadata(cli FileServerClient, ids []string) (map[string]*EncryptedFileMetadata, error) {
 	query := FileServerMessage_ListFileMetadataQuery_{
 		ListFileMetadataQuery: &FileServerMessage_ListFileMetadataQuery{
 			Ids: ids,
@@ -28,49 +63,7 @@ func ListFileMetadata(cli FileServerClient, ids []string, masterKey MasterKey) (
 	}
 
 	if resp, ok := listFileMetadataResponse.Response.(*ListFileMetadataResp_Metadatas); ok {
-		fileMetas := map[string]*FileMetadata{}
-
-		for fileId, metaInfo := range resp.Metadatas.Metadatas {
-			metaId := uuid.MustParse(metaInfo.Id)
-			metaIdBin, err := metaId.MarshalBinary()
-			if err != nil {
-				return fileMetas, err
-			}
-
-			newKeyBytes := masterKey[:]
-			newKeyBytes = append(newKeyBytes, metaIdBin...)
-			newKey := blake3.Sum256(newKeyBytes)
-
-			enc, err := chacha20poly1305.NewX(newKey[:])
-			if err != nil {
-				return fileMetas, err
-			}
-
-			// pad nonce to 24 bytes
-			metaIdBin = append(metaIdBin, make([]byte, 8)...)
-			compressedMetaBytes, err := enc.Open([]byte{}, metaIdBin, metaInfo.Metadata, []byte{})
-			if err != nil {
-				return fileMetas, err
-			}
-
-			zstdDecoder, err := zstd.NewReader(bytes.NewReader(compressedMetaBytes))
-			if err != nil {
-				return fileMetas, err
-			}
-			metaBytes, err := io.ReadAll(zstdDecoder)
-			if err != nil {
-				return fileMetas, err
-			}
-			var fileMeta FileMetadata
-			err = proto.Unmarshal(metaBytes, &fileMeta)
-			if err != nil {
-				return fileMetas, err
-			}
-
-			fileMetas[fileId] = &fileMeta
-		}
-
-		return fileMetas, nil
+		return resp.Metadatas.Metadatas, nil
 	}
 
 	respErr := listFileMetadataResponse.Response.(*ListFileMetadataResp_Err)
@@ -78,6 +71,14 @@ func ListFileMetadata(cli FileServerClient, ids []string, masterKey MasterKey) (
 }
 
 func DownloadFileMetadata(cli FileServerClient, fileId string, masterKey MasterKey) (*FileMetadata, error) {
+	encFileMeta, err := downloadEncryptedFileMetadata(cli, fileId)
+	if err != nil {
+		return nil, err
+	}
+	return decryptFileMetadata(encFileMeta, masterKey)
+}
+
+func downloadEncryptedFileMetadata(cli FileServerClient, fileId string) (*EncryptedFileMetadata, error) {
 	query := FileServerMessage_DownloadFileMetadataQuery_{
 		DownloadFileMetadataQuery: &FileServerMessage_DownloadFileMetadataQuery{
 			Id: fileId,
@@ -90,53 +91,215 @@ func DownloadFileMetadata(cli FileServerClient, fileId string, masterKey MasterK
 	}
 
 	if resp, ok := downloadFileMetadataResponse.Response.(*DownloadFileMetadataResp_EncryptedFileMetadata); ok {
-		metaId := uuid.MustParse(resp.EncryptedFileMetadata.Id)
-		metaIdBin, err := metaId.MarshalBinary()
+		return resp.EncryptedFileMetadata, nil
+	}
 
-		newKeyBytes := masterKey[:]
-		newKeyBytes = append(newKeyBytes, metaIdBin...)
-		newKey := blake3.Sum256(newKeyBytes)
+	respErr := downloadFileMetadataResponse.Response.(*DownloadFileMetadataResp_Err)
+	return nil, errors.New(respErr.Err)
+}
 
-		enc, err := chacha20poly1305.NewX(newKey[:])
-		if err != nil {
-			return nil, err
-		}
+// files with a data key have their metadata wrapped in a FileMetadataEnvelope, marked with this prefix
+const fileMetadataEnvelopeMagic = "bfspenv1"
 
-		// pad nonce to 24 bytes
-		metaIdBin = append(metaIdBin, make([]byte, 8)...)
-		compressedMetaBytes, err := enc.Open([]byte{}, metaIdBin, resp.EncryptedFileMetadata.Metadata, []byte{})
-		if err != nil {
-			return nil, err
-		}
+// isFileMetadata returns whether encFileMeta is a file's metadata, rather than something else stored the same way
+func isFileMetadata(encFileMeta *EncryptedFileMetadata) bool {
+	return !bytes.HasPrefix(encFileMeta.Metadata, []byte(shareRevocationsMagic)) &&
+		!bytes.HasPrefix(encFileMeta.Metadata, []byte(shareManifestMagic))
+}
 
-		zstdDecoder, err := zstd.NewReader(bytes.NewReader(compressedMetaBytes))
+func decryptFileMetadata(encFileMeta *EncryptedFileMetadata, masterKey MasterKey) (*FileMetadata, error) {
+	if envelopeBytes, ok := bytes.CutPrefix(encFileMeta.Metadata, []byte(fileMetadataEnvelopeMagic)); ok {
+		var envelope FileMetadataEnvelope
+		err := proto.Unmarshal(envelopeBytes, &envelope)
 		if err != nil {
 			return nil, err
 		}
-		metaBytes, err := io.ReadAll(zstdDecoder)
+		dataKey, err := unwrapDataKey(envelope.WrappedDataKey, encFileMeta.Id, masterKey)
 		if err != nil {
 			return nil, err
 		}
-		var fileMeta FileMetadata
-		err = proto.Unmarshal(metaBytes, &fileMeta)
+		return openFileMetadataEnvelope(&envelope, encFileMeta.Id, dataKey)
+	}
+
+	fileKey, err := deriveFileKey(masterKey, encFileMeta.Id)
+	if err != nil {
+		return nil, err
+	}
+	return openDerivedKeyFileMetadata(encFileMeta, fileKey)
+}
+
+// decryptFileMetadataWithFileKey decrypts file metadata with the key ShareFile hands out, rather than the master key
+func decryptFileMetadataWithFileKey(encFileMeta *EncryptedFileMetadata, fileKey []byte) (*FileMetadata, error) {
+	if envelopeBytes, ok := bytes.CutPrefix(encFileMeta.Metadata, []byte(fileMetadataEnvelopeMagic)); ok {
+		var envelope FileMetadataEnvelope
+		err := proto.Unmarshal(envelopeBytes, &envelope)
 		if err != nil {
 			return nil, err
 		}
+		return openFileMetadataEnvelope(&envelope, encFileMeta.Id, fileKey)
+	}
+	return openDerivedKeyFileMetadata(encFileMeta, fileKey)
+}
+
+// openDerivedKeyFileMetadata decrypts the metadata of a file without a data key, which is encrypted with the key derived from the master key and its id
+func openDerivedKeyFileMetadata(encFileMeta *EncryptedFileMetadata, fileKey []byte) (*FileMetadata, error) {
+	metaId, err := uuid.Parse(encFileMeta.Id)
+	if err != nil {
+		return nil, err
+	}
 
-		return &fileMeta, nil
+	enc, err := chacha20poly1305.NewX(fileKey)
+	if err != nil {
+		return nil, err
 	}
 
-	respErr := downloadFileMetadataResponse.Response.(*DownloadFileMetadataResp_Err)
-	return nil, errors.New(respErr.Err)
+	// pad nonce to 24 bytes
+	nonce := make([]byte, 24)
+	copy(nonce, metaId[:])
+	compressedMetaBytes, err := enc.Open([]byte{}, nonce, encFileMeta.Metadata, []byte{})
+	if err != nil {
+		return nil, err
+	}
+
+	return decompressFileMetadata(compressedMetaBytes)
+}
+
+func openFileMetadataEnvelope(envelope *FileMetadataEnvelope, fileId string, dataKey []byte) (*FileMetadata, error) {
+	fileUUID, err := uuid.Parse(fileId)
+	if err != nil {
+		return nil, err
+	}
+
+	enc, err := chacha20poly1305.NewX(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(envelope.Nonce) != enc.NonceSize() {
+		return nil, fmt.Errorf("file metadata nonce is %d bytes, expected %d", len(envelope.Nonce), enc.NonceSize())
+	}
+	compressedMetaBytes, err := enc.Open(nil, envelope.Nonce, envelope.EncryptedMetadata, fileUUID[:])
+	if err != nil {
+		return nil, err
+	}
+
+	fileMeta, err := decompressFileMetadata(compressedMetaBytes)
+	if err != nil {
+		return nil, err
+	}
+	if fileMeta.Id != fileId {
+		return nil, fmt.Errorf("file metadata is for file %s, expected %s", fileMeta.Id, fileId)
+	}
+	fileMeta.WrappedDataKey = envelope.WrappedDataKey
+	return fileMeta, nil
+}
+
+func decompressFileMetadata(compressedMetaBytes []byte) (*FileMetadata, error) {
+	metaBytes, err := decompress(compressedMetaBytes, nil)
+	if err != nil {
+		return nil, err
+	}
+	var fileMeta FileMetadata
+	err = proto.Unmarshal(metaBytes, &fileMeta)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileMeta, nil
+}
+
+// encryptFileMetadata encrypts fileMeta with its data key, or with the key derived from masterKey if it doesn't have one.
+// the data key's nonce is random, since unlike the derived key's it would otherwise be reused every time the metadata is updated
+func encryptFileMetadata(fileMeta *FileMetadata, masterKey MasterKey) (*EncryptedFileMetadata, error) {
+	metaBytes, err := proto.Marshal(fileMeta)
+	if err != nil {
+		return nil, err
+	}
+
+	compressedMetaBytes, err := compress(metaBytes, CompressionDefault, fileMeta.Padded)
+	if err != nil {
+		return nil, err
+	}
+	defer putCompressBuffer(compressedMetaBytes)
+
+	uuid, err := uuid.Parse(fileMeta.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	fileKey, err := fileEncKey(fileMeta.Id, fileMeta.WrappedDataKey, masterKey)
+	if err != nil {
+		return nil, err
+	}
+
+	enc, err := chacha20poly1305.NewX(fileKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(fileMeta.WrappedDataKey) == 0 {
+		nonce := make([]byte, 24)
+		copy(nonce, uuid[:])
+
+		return &EncryptedFileMetadata{
+			Metadata: enc.Seal(nil, nonce, compressedMetaBytes, nil),
+			Id:       uuid.String(),
+		}, nil
+	}
+
+	nonce := make([]byte, enc.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+	envelopeBytes, err := proto.Marshal(&FileMetadataEnvelope{
+		WrappedDataKey:    fileMeta.WrappedDataKey,
+		Nonce:             nonce,
+		EncryptedMetadata: enc.Seal(nil, nonce, compressedMetaBytes, uuid[:]),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &EncryptedFileMetadata{
+		Metadata: append([]byte(fileMetadataEnvelopeMagic), envelopeBytes...),
+		Id:       uuid.String(),
+	}, nil
 }
 
 type DownloadChunkArgs struct {
 	ChunkID string
 	FileID  string
-	Token   string
+	// where the chunk is in the file and its plaintext size, from FileMetadata.Chunks and FileMetadata.ChunkSizes.
+	// chunks bound to their file can only be decrypted if these match
+	Indice uint64
+	Size   uint32
+	Token  string
+	// set for chunks of files with FileMetadata.ContentAddressed
+	ContentAddressed bool
+	// FileMetadata.WrappedDataKey, for files that have one
+	WrappedDataKey []byte
 }
 
 func DownloadChunk(cli FileServerClient, args DownloadChunkArgs, masterKey MasterKey) ([]byte, error) {
+	return newChunkCodec(masterKey).downloadChunk(cli, args)
+}
+
+func (c *chunkCodec) downloadChunk(cli FileServerClient, args DownloadChunkArgs) ([]byte, error) {
+	chunkAEAD, err := c.fileAEAD(args.FileID, args.ContentAddressed, args.WrappedDataKey)
+	if err != nil {
+		return nil, err
+	}
+	chunkBin, err := downloadChunk(cli, args, chunkAEAD)
+	if err != nil {
+		return nil, err
+	}
+	if args.ContentAddressed && contentAddressedChunkID(c.masterKey, chunkBin) != args.ChunkID {
+		return nil, fmt.Errorf("chunk id does not match its contents")
+	}
+	return chunkBin, nil
+}
+
+func downloadChunk(cli FileServerClient, args DownloadChunkArgs, enc cipher.AEAD) ([]byte, error) {
 	if args.Token != "" {
 		cli = cli.setToken(args.Token)
 	}
@@ -144,6 +307,7 @@ func DownloadChunk(cli FileServerClient, args DownloadChunkArgs, masterKey Maste
 	query := FileServerMessage_DownloadChunkQuery_{
 		DownloadChunkQuery: &FileServerMessage_DownloadChunkQuery{
 			ChunkId: args.ChunkID,
+			FileId:  args.FileID,
 		},
 	}
 	downloadChunkResponse := DownloadChunkResp{}
@@ -153,20 +317,8 @@ func DownloadChunk(cli FileServerClient, args DownloadChunkArgs, masterKey Maste
 	}
 
 	if resp, ok := downloadChunkResponse.Response.(*DownloadChunkResp_ChunkData_); ok {
-		fileIdUUID := uuid.MustParse(args.FileID)
-		fileIdBin, err := fileIdUUID.MarshalBinary()
-
-		newKeyBytes := masterKey[:]
-		newKeyBytes = append(newKeyBytes, fileIdBin...)
-		newKey := blake3.Sum256(newKeyBytes)
-
-		enc, err := chacha20poly1305.NewX(newKey[:])
 		var chunkBin []byte
 
-		if err != nil {
-			return chunkBin, err
-		}
-
 		var chunkMeta *ChunkMetadata = &ChunkMetadata{}
 		if resp.ChunkData.EncChunkMetadata != nil {
 			encChunkMetadata := resp.ChunkData.EncChunkMetadata.EncMetadata
@@ -178,36 +330,55 @@ func DownloadChunk(cli FileServerClient, args DownloadChunkArgs, masterKey Maste
 				return nil, err
 			}
 
-			copy(nonce[:16], chunkMetaUUID[:])
-			compressedChunkMeta, err := enc.Open(nil, nonce, encChunkMetadata, chunkMetaUUID[:])
+			if args.ContentAddressed {
+				// see compressEncryptContentAddressedChunkMetadata
+				if len(encChunkMetadata) < len(nonce) {
+					return nil, fmt.Errorf("chunk metadata is too short")
+				}
+				copy(nonce, encChunkMetadata[:len(nonce)])
+				encChunkMetadata = encChunkMetadata[len(nonce):]
+			} else {
+				copy(nonce[:16], chunkMetaUUID[:])
+			}
+			// the response is ours, so it's decrypted in place
+			compressedChunkMeta, err := enc.Open(encChunkMetadata[:0], nonce, encChunkMetadata, chunkMetaUUID[:])
 			if err != nil {
 				return chunkBin, err
 			}
-			zstdDecoder, err := zstd.NewReader(nil)
+			chunkMetaBin, err := decompress(compressedChunkMeta, nil)
 			if err != nil {
 				return nil, err
 			}
-			defer zstdDecoder.Close()
-
-			chunkMetaBin, err := zstdDecoder.DecodeAll(compressedChunkMeta, nil)
 			proto.Unmarshal(chunkMetaBin, chunkMeta)
 		} else {
 			chunkMeta = resp.ChunkData.ChunkMetadata
 		}
 
-		compressedChunkBytes, err := enc.Open([]byte{}, chunkMeta.Nonce, resp.ChunkData.Chunk, []byte(chunkMeta.Id))
+		// the chunk metadata is only authenticated with whatever id the server sent along with it
+		if chunkMeta.Id != args.ChunkID {
+			return nil, fmt.Errorf("got chunk %s, expected %s", chunkMeta.Id, args.ChunkID)
+		}
+		additionalData, err := chunkAdditionalData(chunkMeta.FormatVersion, args.FileID, args.ChunkID, args.Indice, args.Size)
 		if err != nil {
-			return chunkBin, err
+			return nil, err
 		}
-
-		zstdDecoder, err := zstd.NewReader(bytes.NewReader(compressedChunkBytes))
+		compressedChunkBytes, err := enc.Open(resp.ChunkData.Chunk[:0], chunkMeta.Nonce, resp.ChunkData.Chunk, additionalData)
 		if err != nil {
 			return chunkBin, err
 		}
 
-		chunkBin, err = io.ReadAll(zstdDecoder)
-		if err != nil {
-			return chunkBin, err
+		if chunkMeta.Uncompressed {
+			// the response is ours, so the chunk is used where it was decrypted
+			if uint64(len(compressedChunkBytes)) < uint64(chunkMeta.Size) {
+				return nil, fmt.Errorf("chunk is %d bytes, expected at least %d", len(compressedChunkBytes), chunkMeta.Size)
+			}
+			chunkBin = compressedChunkBytes[:chunkMeta.Size]
+		} else {
+			// the plaintext size is known up front, so it only needs one allocation
+			chunkBin, err = decompress(compressedChunkBytes, make([]byte, 0, args.Size))
+			if err != nil {
+				return chunkBin, err
+			}
 		}
 
 		hash := blake3.Sum256(chunkBin)
@@ -223,47 +394,21 @@ func DownloadChunk(cli FileServerClient, args DownloadChunkArgs, masterKey Maste
 }
 
 func UploadFileMetadata(cli FileServerClient, fileMeta *FileMetadata, masterKey MasterKey) error {
-	metaBytes, err := proto.Marshal(fileMeta)
-	if err != nil {
-		return err
-	}
-
-	zstdEncoder, err := zstd.NewWriter(nil)
+	encFileMeta, err := encryptFileMetadata(fileMeta, masterKey)
 	if err != nil {
 		return err
 	}
-	defer zstdEncoder.Close()
-
-	compressedMetaBytes := zstdEncoder.EncodeAll(metaBytes, nil)
-
-	uuid, err := uuid.Parse(fileMeta.Id)
-	if err != nil {
-		return err
-	}
-	nonce := make([]byte, 24)
-	copy(nonce, uuid[:])
-
-	newKeyBytes := masterKey[:]
-	newKeyBytes = append(newKeyBytes, uuid[:]...)
-	fileKey := blake3.Sum256(newKeyBytes)
-
-	enc, err := chacha20poly1305.NewX(fileKey[:])
-	if err != nil {
-		return err
-	}
-
-	encryptedMetaBytes := enc.Seal(nil, nonce, compressedMetaBytes, nil)
+	return uploadEncryptedFileMetadata(cli, encFileMeta)
+}
 
+func uploadEncryptedFileMetadata(cli FileServerClient, encFileMeta *EncryptedFileMetadata) error {
 	query := FileServerMessage_UploadFileMetadata_{
 		UploadFileMetadata: &FileServerMessage_UploadFileMetadata{
-			EncryptedFileMetadata: &EncryptedFileMetadata{
-				Metadata: encryptedMetaBytes,
-				Id:       uuid.String(),
-			},
+			EncryptedFileMetadata: encFileMeta,
 		},
 	}
 	uploadFileMetadataResponse := UploadFileMetadataResp{}
-	err = cli.SendFileServerMessage(&query, &uploadFileMetadataResponse)
+	err := cli.SendFileServerMessage(&query, &uploadFileMetadataResponse)
 	if err != nil {
 		return err
 	}
@@ -276,47 +421,21 @@ func UploadFileMetadata(cli FileServerClient, fileMeta *FileMetadata, masterKey
 }
 
 func UpdateFileMetadata(cli FileServerClient, fileMeta *FileMetadata, masterKey MasterKey) error {
-	metaBytes, err := proto.Marshal(fileMeta)
-	if err != nil {
-		return err
-	}
-
-	zstdEncoder, err := zstd.NewWriter(nil)
-	if err != nil {
-		return err
-	}
-	defer zstdEncoder.Close()
-
-	compressedMetaBytes := zstdEncoder.EncodeAll(metaBytes, nil)
-
-	uuid, err := uuid.Parse(fileMeta.Id)
+	encFileMeta, err := encryptFileMetadata(fileMeta, masterKey)
 	if err != nil {
 		return err
 	}
-	nonce := make([]byte, 24)
-	copy(nonce, uuid[:])
-
-	newKeyBytes := masterKey[:]
-	newKeyBytes = append(newKeyBytes, uuid[:]...)
-	fileKey := blake3.Sum256(newKeyBytes)
-
-	enc, err := chacha20poly1305.NewX(fileKey[:])
-	if err != nil {
-		return err
-	}
-
-	encryptedMetaBytes := enc.Seal(nil, nonce, compressedMetaBytes, nil)
+	return updateEncryptedFileMetadata(cli, encFileMeta)
+}
 
+func updateEncryptedFileMetadata(cli FileServerClient, encFileMeta *EncryptedFileMetadata) error {
 	query := FileServerMessage_UpdateFileMetadata_{
 		UpdateFileMetadata: &FileServerMessage_UpdateFileMetadata{
-			EncryptedFileMetadata: &EncryptedFileMetadata{
-				Metadata: encryptedMetaBytes,
-				Id:       uuid.String(),
-			},
+			EncryptedFileMetadata: encFileMeta,
 		},
 	}
 	updateFileMetadataResponse := UpdateFileMetadataResp{}
-	err = cli.SendFileServerMessage(&query, &updateFileMetadataResponse)
+	err := cli.SendFileServerMessage(&query, &updateFileMetadataResponse)
 	if err != nil {
 		return err
 	}
@@ -328,34 +447,83 @@ func UpdateFileMetadata(cli FileServerClient, fileMeta *FileMetadata, masterKey
 	return nil
 }
 
+// UploadChunk uploads a chunk of a file without a data key, encrypted with CompressEncryptChunk
 func UploadChunk(cli FileServerClient, chunkMetadata *ChunkMetadata, fileUUIDStr string, encryptedCompressedChunkBytes EncryptedCompressedChunk, masterKey MasterKey) error {
-	compressedEncryptedMetaBytes, err := CompressEncryptChunkMetadata(chunkMetadata, fileUUIDStr, masterKey)
+	return UploadChunkWithDataKey(cli, chunkMetadata, &FileMetadata{Id: fileUUIDStr}, encryptedCompressedChunkBytes, masterKey)
+}
+
+// UploadChunkWithDataKey uploads a chunk of fileMeta, encrypted with CompressEncryptChunkWithDataKey
+func UploadChunkWithDataKey(cli FileServerClient, chunkMetadata *ChunkMetadata, fileMeta *FileMetadata, encryptedCompressedChunkBytes EncryptedCompressedChunk, masterKey MasterKey) error {
+	compressedEncryptedMetaBytes, err := CompressEncryptChunkMetadataWithDataKey(chunkMetadata, fileMeta, masterKey)
 	if err != nil {
 		return err
 	}
 
+	return uploadChunk(cli, chunkMetadata.Id, fileMeta.Id, compressedEncryptedMetaBytes, encryptedCompressedChunkBytes)
+}
+
+func uploadChunk(cli FileServerClient, chunkId string, fileId string, compressedEncryptedMetaBytes []byte, encryptedCompressedChunkBytes EncryptedCompressedChunk) error {
 	query := FileServerMessage_UploadChunk_{
 		UploadChunk: &FileServerMessage_UploadChunk{
 			EncChunkMetadata: &EncryptedChunkMetadata{
-				Id:          chunkMetadata.Id,
+				Id:          chunkId,
 				EncMetadata: compressedEncryptedMetaBytes,
 			},
-			Chunk: encryptedCompressedChunkBytes.chunk,
+			Chunk:  encryptedCompressedChunkBytes.chunk,
+			FileId: fileId,
 		},
 	}
 	uploadChunkResponse := UploadChunkResp{}
-	err = cli.SendFileServerMessage(&query, &uploadChunkResponse)
+	err := cli.SendFileServerMessage(&query, &uploadChunkResponse)
 	if err != nil {
 		return err
 	}
 
 	if uploadChunkResponse.Err != nil {
-		return errors.New(*uploadChunkResponse.Err)
+		return &serverError{msg: *uploadChunkResponse.Err}
 	}
 
 	return nil
 }
 
+// serverError is an error the server reported in its response, rather than one sending the message. Retrying won't help with it
+type serverError struct {
+	msg string
+}
+
+func (err *serverError) Error() string {
+	return err.msg
+}
+
+// ChunksUploaded asks the server which of chunkIDs it already has
+func ChunksUploaded(cli FileServerClient, chunkIDs []string) (map[string]bool, error) {
+	query := FileServerMessage_ChunksUploadedQuery_{
+		ChunksUploadedQuery: &FileServerMessage_ChunksUploadedQuery{
+			ChunkIds: chunkIDs,
+		},
+	}
+	chunksUploadedResponse := ChunksUploadedQueryResp{}
+	err := cli.SendFileServerMessage(&query, &chunksUploadedResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp, ok := chunksUploadedResponse.Response.(*ChunksUploadedQueryResp_Chunks); ok {
+		chunksUploaded := map[string]bool{}
+		for _, chunk := range resp.Chunks.Chunks {
+			chunkID, err := uuid.FromBytes(chunk.ChunkId)
+			if err != nil {
+				return nil, err
+			}
+			chunksUploaded[chunkID.String()] = chunk.Uploaded
+		}
+		return chunksUploaded, nil
+	}
+
+	respErr := chunksUploadedResponse.Response.(*ChunksUploadedQueryResp_Err)
+	return nil, errors.New(respErr.Err)
+}
+
 func DeleteFileMetadata(cli FileServerClient, fileID string) error {
 	query := FileServerMessage_DeleteFileMetadataQuery_{
 		DeleteFileMetadataQuery: &FileServerMessage_DeleteFileMetadataQuery{
@@ -394,6 +562,58 @@ func DeleteChunks(cli FileServerClient, chunkIDs []string) error {
 	return nil
 }
 
+// StoreMasterKey encrypts masterKey with password and stores it on the file server, so it can be recovered on another device with FetchMasterKey
+func StoreMasterKey(cli FileServerClient, masterKey MasterKey, password string) error {
+	escrowedKey, err := escrowMasterKey(masterKey, password)
+	if err != nil {
+		return err
+	}
+	escrowedKeyBytes, err := proto.Marshal(escrowedKey)
+	if err != nil {
+		return err
+	}
+
+	query := FileServerMessage_SetMasterKey{
+		SetMasterKey: &FileServerMessage_SetMasterEncryptionKey{
+			EncryptedKey: escrowedKeyBytes,
+		},
+	}
+	setMasterKeyResp := SetMasterEncryptionKeyResp{}
+	err = cli.SendFileServerMessage(&query, &setMasterKeyResp)
+	if err != nil {
+		return err
+	}
+
+	if setMasterKeyResp.Err != nil {
+		return errors.New(*setMasterKeyResp.Err)
+	}
+	return nil
+}
+
+// FetchMasterKey downloads the master key stored with StoreMasterKey and decrypts it with password. ErrIncorrectPassword is returned if password is wrong
+func FetchMasterKey(cli FileServerClient, password string) (MasterKey, error) {
+	query := FileServerMessage_GetMasterKey{
+		GetMasterKey: &FileServerMessage_GetMasterEncryptionKey{},
+	}
+	getMasterKeyResp := GetMasterEncryptionKeyResp{}
+	err := cli.SendFileServerMessage(&query, &getMasterKeyResp)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp, ok := getMasterKeyResp.Response.(*GetMasterEncryptionKeyResp_EncryptedKey); ok {
+		var escrowedKey EscrowedMasterKey
+		err = proto.Unmarshal(resp.EncryptedKey, &escrowedKey)
+		if err != nil {
+			return nil, err
+		}
+		return unescrowMasterKey(&escrowedKey, password)
+	}
+
+	respErr := getMasterKeyResp.Response.(*GetMasterEncryptionKeyResp_Err)
+	return nil, errors.New(respErr.Err)
+}
+
 func encodeFileServerMessage(msg isFileServerMessage_Message, token string) ([]byte, error) {
 	fullMessage := FileServerMessage{
 		Auth: &FileServerMessage_Authentication{
@@ -414,3 +634,51 @@ func encodeFileServerMessage(msg isFileServerMessage_Message, token string) ([]b
 
 	return fullMsgBin, nil
 }
+
+// MaxFileServerMessageSize is the biggest FileServerMessage ReadFileServerMessage accepts. It fits the largest padded chunk, with plenty of room for the metadata of a file with tens of thousands of chunks
+const MaxFileServerMessageSize = 32 * 1024 * 1024
+
+// ReadFileServerMessage reads a single length-prefixed FileServerMessage, as sent by SendFileServerMessage
+func ReadFileServerMessage(r io.Reader) (*FileServerMessage, error) {
+	var msgLen uint32
+	err := binary.Read(r, binary.LittleEndian, &msgLen)
+	if err != nil {
+		return nil, err
+	}
+	if msgLen > MaxFileServerMessageSize {
+		return nil, fmt.Errorf("message is %d bytes, more than the maximum of %d", msgLen, MaxFileServerMessageSize)
+	}
+
+	// the length can't be trusted, so memory is only used as the message actually arrives
+	msgBin, err := io.ReadAll(io.LimitReader(r, int64(msgLen)))
+	if err != nil {
+		return nil, err
+	}
+	if len(msgBin) != int(msgLen) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	var msg FileServerMessage
+	err = proto.Unmarshal(msgBin, &msg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &msg, nil
+}
+
+// EncodeFileServerResponse encodes a response the same way the file server does, prefixed with its size as uint32-le
+func EncodeFileServerResponse(resp proto.Message) ([]byte, error) {
+	respBin, err := proto.Marshal(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	respLen := uint32(len(respBin))
+	fullRespBin := make([]byte, 4+respLen)
+
+	binary.LittleEndian.PutUint32(fullRespBin[:4], respLen)
+	copy(fullRespBin[4:], respBin)
+
+	return fullRespBin, nil
+}
@@ -0,0 +1,82 @@
+// Package bfsptest provides an in-memory file server for testing code that uses bfsp.
+// it's package server backed by server.MemoryStorage, so tests run against the same code people self-host, tokens and all
+package bfsptest
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/BillysBigFileServer/bfsp-go"
+	"github.com/BillysBigFileServer/bfsp-go/server"
+)
+
+// UserID is the user that Token and Client act as
+const UserID = 1
+
+// Server is a server.Server backed by memory, with its own root key so tests can mint tokens
+type Server struct {
+	*server.Server
+
+	Storage *server.MemoryStorage
+	rootKey ed25519.PrivateKey
+	token   string
+}
+
+func NewServer() *Server {
+	rootPubKey, rootKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	token, err := server.NewToken(rootKey, UserID)
+	if err != nil {
+		panic(err)
+	}
+
+	storage := server.NewMemoryStorage()
+	return &Server{
+		Server:  server.New(storage, rootPubKey),
+		Storage: storage,
+		rootKey: rootKey,
+		token:   token,
+	}
+}
+
+// Token returns a token for UserID
+func (s *Server) Token() string {
+	return s.token
+}
+
+// NewToken returns a token for any user, signed by the server's root key
+func (s *Server) NewToken(userID int64) (string, error) {
+	return server.NewToken(s.rootKey, userID)
+}
+
+// Client returns a FileServerClient for UserID that calls the server directly, without going over HTTP
+func (s *Server) Client() bfsp.FileServerClient {
+	cli, _ := bfsp.NewDirectFileServerClient(s.token, s)
+	return cli
+}
+
+// NewHTTPServer starts an httptest.Server that serves the file server protocol on /api
+func (s *Server) NewHTTPServer() *httptest.Server {
+	return httptest.NewServer(s)
+}
+
+// NewHTTPClient returns a FileServerClient that talks to an httptest.Server started with NewHTTPServer
+func NewHTTPClient(ts *httptest.Server, token string) (bfsp.FileServerClient, error) {
+	return bfsp.NewHTTPFileServerClient(token, strings.TrimPrefix(ts.URL, "http://"), false)
+}
+
+// ChunkCount returns the number of chunks stored for UserID
+func (s *Server) ChunkCount() int {
+	chunkIDs, _ := s.Storage.ListChunkIDs(UserID)
+	return len(chunkIDs)
+}
+
+// HasChunk reports whether a chunk is stored for UserID
+func (s *Server) HasChunk(chunkID string) bool {
+	ok, _ := s.Storage.HasChunk(UserID, chunkID)
+	return ok
+}
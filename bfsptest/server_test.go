@@ -0,0 +1,211 @@
+package bfsptest_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"testing"
+
+	"github.com/BillysBigFileServer/bfsp-go"
+	"github.com/BillysBigFileServer/bfsp-go/bfsptest"
+	"github.com/BillysBigFileServer/bfsp-go/server"
+	"github.com/BillysBigFileServer/bfsp-go/usage"
+	"github.com/google/uuid"
+)
+
+// forEachClient runs test against a new server, once with a direct client and once with one that goes over HTTP
+func forEachClient(t *testing.T, test func(t *testing.T, srv *bfsptest.Server, cli bfsp.FileServerClient)) {
+	t.Run("direct", func(t *testing.T) {
+		srv := bfsptest.NewServer()
+		test(t, srv, srv.Client())
+	})
+	t.Run("http", func(t *testing.T) {
+		srv := bfsptest.NewServer()
+		ts := srv.NewHTTPServer()
+		defer ts.Close()
+		httpClient, err := bfsptest.NewHTTPClient(ts, srv.Token())
+		if err != nil {
+			t.Fatal(err)
+		}
+		test(t, srv, httpClient)
+	})
+}
+
+func TestServerRoundTrip(t *testing.T) {
+	forEachClient(t, func(t *testing.T, srv *bfsptest.Server, cli bfsp.FileServerClient) {
+		masterKey := make(bfsp.MasterKey, 32)
+		rand.Read(masterKey)
+		ctx := bfsp.ContextWithMasterKey(bfsp.ContextWithClient(context.Background(), cli), masterKey)
+
+		data := make([]byte, 3*bfsp.ChunkSize+5)
+		rand.Read(data)
+		res, err := bfsp.UploadFile(ctx, &bfsp.FileInfo{Name: "file", Reader: bytes.NewReader(data)}, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fileMetas, err := bfsp.ListFileMetadata(cli, []string{res.FileMetadata.Id}, masterKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fileMeta, ok := fileMetas[res.FileMetadata.Id]
+		if !ok || fileMeta.FileName != "file" {
+			t.Fatalf("uploaded file isn't listed: %v", fileMetas)
+		}
+
+		var downloaded bytes.Buffer
+		err = bfsp.DownloadFile(ctx, fileMeta, &downloaded, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(downloaded.Bytes(), data) {
+			t.Fatal("downloaded file doesn't match what was uploaded")
+		}
+
+		err = bfsp.DeleteFileMetadata(cli, fileMeta.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = bfsp.DownloadFileMetadata(cli, fileMeta.Id, masterKey)
+		if err == nil {
+			t.Fatal("expected an error downloading deleted file metadata")
+		}
+	})
+}
+
+func TestServerChunks(t *testing.T) {
+	forEachClient(t, func(t *testing.T, srv *bfsptest.Server, cli bfsp.FileServerClient) {
+		masterKey := make(bfsp.MasterKey, 32)
+		rand.Read(masterKey)
+		ctx := bfsp.ContextWithMasterKey(bfsp.ContextWithClient(context.Background(), cli), masterKey)
+
+		res, err := bfsp.UploadFile(ctx, &bfsp.FileInfo{Name: "file", Reader: bytes.NewReader(make([]byte, 2*bfsp.ChunkSize))}, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunkIDs := []string{res.FileMetadata.Chunks[0], res.FileMetadata.Chunks[1]}
+		missingID := uuid.NewString()
+
+		uploaded, err := bfsp.ChunksUploaded(cli, append([]string{missingID}, chunkIDs...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if uploaded[missingID] || !uploaded[chunkIDs[0]] || !uploaded[chunkIDs[1]] {
+			t.Fatalf("wrong chunks uploaded: %v", uploaded)
+		}
+
+		listResp := bfsp.ListChunkMetadataResp{}
+		err = cli.SendFileServerMessage(&bfsp.FileServerMessage_ListChunkMetadataQuery_{
+			ListChunkMetadataQuery: &bfsp.FileServerMessage_ListChunkMetadataQuery{},
+		}, &listResp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if chunkMetas := listResp.GetMetadatas().GetMetadatas(); len(chunkMetas) != 2 || chunkMetas[chunkIDs[0]] == nil {
+			t.Fatalf("wrong chunks listed: %v", chunkMetas)
+		}
+
+		err = bfsp.DeleteChunks(cli, chunkIDs[:1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		uploaded, err = bfsp.ChunksUploaded(cli, chunkIDs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if uploaded[chunkIDs[0]] || !uploaded[chunkIDs[1]] {
+			t.Fatalf("wrong chunks left after deleting one: %v", uploaded)
+		}
+	})
+}
+
+func TestServerUsageAndStorageCap(t *testing.T) {
+	forEachClient(t, func(t *testing.T, srv *bfsptest.Server, cli bfsp.FileServerClient) {
+		masterKey := make(bfsp.MasterKey, 32)
+		rand.Read(masterKey)
+		ctx := bfsp.ContextWithMasterKey(bfsp.ContextWithClient(context.Background(), cli), masterKey)
+
+		before, err := usage.GetUsage(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = bfsp.UploadFile(ctx, &bfsp.FileInfo{Name: "file", Reader: bytes.NewReader(make([]byte, 1000))}, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		after, err := usage.GetUsage(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if after.TotalUsage <= before.TotalUsage || after.StorageCap != server.DefaultStorageCap {
+			t.Fatalf("got usage %+v after uploading, %+v before", after, before)
+		}
+
+		srv.StorageCap = after.TotalUsage
+		_, err = bfsp.UploadFile(ctx, &bfsp.FileInfo{Name: "too big", Reader: bytes.NewReader(make([]byte, 1000))}, 1)
+		if err == nil {
+			t.Fatal("uploaded a file over the storage cap")
+		}
+	})
+}
+
+func TestServerMasterKey(t *testing.T) {
+	forEachClient(t, func(t *testing.T, srv *bfsptest.Server, cli bfsp.FileServerClient) {
+		_, err := bfsp.FetchMasterKey(cli, "password")
+		if err == nil {
+			t.Fatal("fetched a master key before one was stored")
+		}
+
+		masterKey := make(bfsp.MasterKey, 32)
+		rand.Read(masterKey)
+		err = bfsp.StoreMasterKey(cli, masterKey, "password")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fetchedKey, err := bfsp.FetchMasterKey(cli, "password")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(fetchedKey, masterKey) {
+			t.Fatal("fetched master key doesn't match the stored one")
+		}
+	})
+}
+
+// failures are reported inside the response like the hosted server does, not as errors sending the message
+func TestServerErrorResponses(t *testing.T) {
+	forEachClient(t, func(t *testing.T, srv *bfsptest.Server, cli bfsp.FileServerClient) {
+		downloadChunkResp := bfsp.DownloadChunkResp{}
+		err := cli.SendFileServerMessage(&bfsp.FileServerMessage_DownloadChunkQuery_{
+			DownloadChunkQuery: &bfsp.FileServerMessage_DownloadChunkQuery{ChunkId: uuid.NewString()},
+		}, &downloadChunkResp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := downloadChunkResp.Response.(*bfsp.DownloadChunkResp_Err); !ok {
+			t.Fatalf("got %v downloading a chunk that doesn't exist, expected an error response", downloadChunkResp.Response)
+		}
+
+		uploadChunkResp := bfsp.UploadChunkResp{}
+		err = cli.SendFileServerMessage(&bfsp.FileServerMessage_UploadChunk_{
+			UploadChunk: &bfsp.FileServerMessage_UploadChunk{Chunk: []byte("no metadata")},
+		}, &uploadChunkResp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if uploadChunkResp.Err == nil {
+			t.Fatal("uploaded a chunk without metadata")
+		}
+
+		fileMetaResp := bfsp.DownloadFileMetadataResp{}
+		err = cli.SendFileServerMessage(&bfsp.FileServerMessage_DownloadFileMetadataQuery_{
+			DownloadFileMetadataQuery: &bfsp.FileServerMessage_DownloadFileMetadataQuery{Id: "not an id"},
+		}, &fileMetaResp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := fileMetaResp.Response.(*bfsp.DownloadFileMetadataResp_Err); !ok {
+			t.Fatalf("got %v downloading file metadata with an invalid id, expected an error response", fileMetaResp.Response)
+		}
+	})
+}
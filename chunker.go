@@ -0,0 +1,145 @@
+package bfsp
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"lukechampine.com/blake3"
+)
+
+const ChunkSize = 1024 * 1024
+
+// chunker splits a file into the chunks UploadFile sends to the server
+type chunker interface {
+	// next returns the next chunk of the file, or io.EOF once it's been fully read
+	next() ([]byte, error)
+}
+
+// fixedSizeChunker splits a file into ChunkSize chunks. Only the last chunk can be shorter, no matter how the reader splits up its reads
+type fixedSizeChunker struct {
+	reader io.Reader
+	size   int
+}
+
+func newFixedSizeChunker(reader io.Reader) *fixedSizeChunker {
+	return &fixedSizeChunker{
+		reader: reader,
+		size:   ChunkSize,
+	}
+}
+
+func (c *fixedSizeChunker) next() ([]byte, error) {
+	buf := make([]byte, c.size)
+	n, err := io.ReadFull(c.reader, buf)
+	switch {
+	case errors.Is(err, io.EOF):
+		return nil, io.EOF
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return buf[:n], nil
+	case err != nil:
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+// content defined chunk sizes. ChunkMetadata.Size is a uint32, so the max has plenty of headroom
+const (
+	cdcMinSize = 256 * 1024
+	cdcAvgSize = ChunkSize
+	cdcMaxSize = 4 * 1024 * 1024
+)
+
+// FastCDC's normalized chunking: cut points are harder to hit before cdcAvgSize and easier after, which keeps chunk sizes close to the average.
+// the gear hash shifts left, so its top bits depend on the most input
+const (
+	cdcMaskHard = uint64(1<<22-1) << (64 - 22)
+	cdcMaskEasy = uint64(1<<18-1) << (64 - 18)
+)
+
+// the gear table has to stay the same forever, otherwise the same data would be split differently and stop deduplicating
+var cdcGear = func() [256]uint64 {
+	var gear [256]uint64
+	seed := blake3.New(32, nil)
+	seed.Write([]byte("bfsp fastcdc gear table"))
+	xof := seed.XOF()
+	for i := range gear {
+		var b [8]byte
+		io.ReadFull(xof, b[:])
+		gear[i] = binary.LittleEndian.Uint64(b[:])
+	}
+	return gear
+}()
+
+// contentDefinedChunker splits a file with FastCDC, so inserting or removing bytes only changes the chunks around the edit
+type contentDefinedChunker struct {
+	reader io.Reader
+	// read from the reader but not returned yet
+	buf []byte
+	eof bool
+}
+
+func newContentDefinedChunker(reader io.Reader) *contentDefinedChunker {
+	return &contentDefinedChunker{
+		reader: reader,
+	}
+}
+
+func (c *contentDefinedChunker) next() ([]byte, error) {
+	// always look at a full window, so the cut points only depend on the data
+	if !c.eof && len(c.buf) < cdcMaxSize {
+		newBuf := make([]byte, cdcMaxSize)
+		n := copy(newBuf, c.buf)
+		m, err := io.ReadFull(c.reader, newBuf[n:])
+		switch {
+		case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+			c.eof = true
+		case err != nil:
+			return nil, err
+		}
+		c.buf = newBuf[:n+m]
+	}
+
+	if len(c.buf) == 0 {
+		return nil, io.EOF
+	}
+
+	cut := cdcCutPoint(c.buf)
+	chunk := append([]byte{}, c.buf[:cut]...)
+	c.buf = c.buf[cut:]
+
+	return chunk, nil
+}
+
+// cdcCutPoint returns the length of the next chunk in data
+func cdcCutPoint(data []byte) int {
+	n := len(data)
+	if n <= cdcMinSize {
+		return n
+	}
+	if n > cdcMaxSize {
+		n = cdcMaxSize
+	}
+	normal := cdcAvgSize
+	if n < normal {
+		normal = n
+	}
+
+	var hash uint64 = 0
+	i := cdcMinSize
+	for ; i < normal; i++ {
+		hash = (hash << 1) + cdcGear[data[i]]
+		if hash&cdcMaskHard == 0 {
+			return i + 1
+		}
+	}
+	for ; i < n; i++ {
+		hash = (hash << 1) + cdcGear[data[i]]
+		if hash&cdcMaskEasy == 0 {
+			return i + 1
+		}
+	}
+
+	return n
+}
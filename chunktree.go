@@ -0,0 +1,63 @@
+package bfsp
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+
+	"lukechampine.com/blake3"
+)
+
+var ErrChunkTreeMismatch = errors.New("file's chunks don't match its chunk tree hash")
+
+// domain separation for the nodes of a chunk tree, so a leaf can never be mistaken for an inner node or the root
+const (
+	chunkTreeLeafPrefix  = 0
+	chunkTreeInnerPrefix = 1
+	chunkTreeRootPrefix  = 2
+)
+
+// chunkTreeLeaf is what a chunk tree knows about each chunk
+type chunkTreeLeaf struct {
+	indice uint64
+	size   uint32
+	hash   [32]byte
+}
+
+// chunkTreeHash hashes leaves into FileMetadata.ChunkTreeHash. Leaves are sorted by indice first, and each one commits to its indice and size as well as its hash.
+// pairs of nodes are hashed together until only one is left, an odd node out is carried up a level as is. The root commits to the number of chunks too
+func chunkTreeHash(leaves []chunkTreeLeaf) []byte {
+	sort.Slice(leaves, func(i, j int) bool { return leaves[i].indice < leaves[j].indice })
+
+	level := make([][32]byte, len(leaves))
+	for i, leaf := range leaves {
+		leafBytes := []byte{chunkTreeLeafPrefix}
+		leafBytes = binary.LittleEndian.AppendUint64(leafBytes, leaf.indice)
+		leafBytes = binary.LittleEndian.AppendUint32(leafBytes, leaf.size)
+		leafBytes = append(leafBytes, leaf.hash[:]...)
+		level[i] = blake3.Sum256(leafBytes)
+	}
+
+	for len(level) > 1 {
+		nextLevel := make([][32]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				nextLevel = append(nextLevel, level[i])
+				break
+			}
+			nodeBytes := []byte{chunkTreeInnerPrefix}
+			nodeBytes = append(nodeBytes, level[i][:]...)
+			nodeBytes = append(nodeBytes, level[i+1][:]...)
+			nextLevel = append(nextLevel, blake3.Sum256(nodeBytes))
+		}
+		level = nextLevel
+	}
+
+	rootBytes := []byte{chunkTreeRootPrefix}
+	rootBytes = binary.LittleEndian.AppendUint64(rootBytes, uint64(len(leaves)))
+	if len(level) == 1 {
+		rootBytes = append(rootBytes, level[0][:]...)
+	}
+	root := blake3.Sum256(rootBytes)
+	return root[:]
+}
@@ -0,0 +1,59 @@
+package bfsp_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/BillysBigFileServer/bfsp-go"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestChunkTreeMismatch(t *testing.T) {
+	ctx, _, _ := newTestContext(t)
+	// content addressed chunks aren't bound to their position, so only the chunk tree catches them being moved
+	fileMeta, data := uploadTestFile(t, ctx, "file", 8*bfsp.ChunkSize, bfsp.WithContentDefinedChunking())
+	if len(fileMeta.Chunks) < 3 {
+		t.Fatalf("file only has %d chunks", len(fileMeta.Chunks))
+	}
+
+	err := bfsp.VerifyFile(ctx, fileMeta, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var downloaded bytes.Buffer
+	err = bfsp.DownloadFile(ctx, fileMeta, &downloaded, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(downloaded.Bytes(), data) {
+		t.Fatal("downloaded file doesn't match what was uploaded")
+	}
+
+	lastIndice := uint64(len(fileMeta.Chunks) - 1)
+	tests := map[string]func(fileMeta *bfsp.FileMetadata){
+		"removed": func(fileMeta *bfsp.FileMetadata) {
+			delete(fileMeta.Chunks, lastIndice)
+			delete(fileMeta.ChunkSizes, lastIndice)
+		},
+		"reordered": func(fileMeta *bfsp.FileMetadata) {
+			fileMeta.Chunks[0], fileMeta.Chunks[1] = fileMeta.Chunks[1], fileMeta.Chunks[0]
+			fileMeta.ChunkSizes[0], fileMeta.ChunkSizes[1] = fileMeta.ChunkSizes[1], fileMeta.ChunkSizes[0]
+		},
+		"duplicated": func(fileMeta *bfsp.FileMetadata) {
+			fileMeta.Chunks[1] = fileMeta.Chunks[0]
+			fileMeta.ChunkSizes[1] = fileMeta.ChunkSizes[0]
+		},
+	}
+	for name, modify := range tests {
+		t.Run(name, func(t *testing.T) {
+			tamperedFileMeta := proto.Clone(fileMeta).(*bfsp.FileMetadata)
+			modify(tamperedFileMeta)
+
+			err := bfsp.VerifyFile(ctx, tamperedFileMeta, "")
+			if !errors.Is(err, bfsp.ErrChunkTreeMismatch) {
+				t.Fatalf("got error %v, expected ErrChunkTreeMismatch", err)
+			}
+		})
+	}
+}
@@ -1,20 +1,24 @@
 package bfsp
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"io"
+	"io/fs"
+	"slices"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/biscuit-auth/biscuit-go/v2"
 	"github.com/biscuit-auth/biscuit-go/v2/parser"
 	"github.com/cenkalti/backoff/v4"
 	"github.com/google/uuid"
-	"github.com/klauspost/compress/zstd"
 	"golang.org/x/sync/errgroup"
 	"google.golang.org/protobuf/proto"
 	"lukechampine.com/blake3"
@@ -23,6 +27,8 @@ import (
 type FileServerClient interface {
 	SendFileServerMessage(msg isFileServerMessage_Message, resp proto.Message) error
 	setToken(token string) FileServerClient
+	// withContext returns a client whose requests are aborted when ctx is done
+	withContext(ctx context.Context) FileServerClient
 }
 
 type EncryptedCompressedChunk struct {
@@ -41,21 +47,115 @@ func ClientFromContext(ctx context.Context) FileServerClient {
 	return ctx.Value(clientContextKey).(FileServerClient)
 }
 
-func ShareFile(fileMeta *FileMetadata, tokenStr string, masterKey MasterKey) (*ViewFileInfo, error) {
-	fileUUID := uuid.MustParse(fileMeta.Id)
-	fileUUIDBin, err := fileUUID.MarshalBinary()
+// ShareAccess is what the recipient of a shared file can do with it
+type ShareAccess int
+
+const (
+	// the recipient can download the file
+	ShareReadOnly ShareAccess = iota
+	// the recipient can upload chunks and the file's metadata, but can't download anything, like a drop box.
+	// they still get the file's key, since they need it to encrypt what they upload
+	ShareWriteOnly
+	// the recipient can download and modify the file
+	ShareReadWrite
+)
+
+// rights returns the rights a share token with this access is attenuated to
+func (access ShareAccess) rights() (biscuit.Set, error) {
+	switch access {
+	case ShareReadOnly:
+		return biscuit.Set{biscuit.String("read")}, nil
+	case ShareWriteOnly:
+		return biscuit.Set{biscuit.String("write")}, nil
+	case ShareReadWrite:
+		return biscuit.Set{biscuit.String("read"), biscuit.String("write")}, nil
+	default:
+		return nil, fmt.Errorf("unknown share access %d", access)
+	}
+}
+
+type shareOptions struct {
+	access  ShareAccess
+	expiry  time.Time
+	shareID string
+}
+
+type ShareOption func(*shareOptions)
+
+// WithShareAccess sets what the recipient can do with the shared file. Files are shared read only by default
+func WithShareAccess(access ShareAccess) ShareOption {
+	return func(opts *shareOptions) {
+		opts.access = access
+	}
+}
+
+// WithShareExpiry makes the share stop working after expiry
+func WithShareExpiry(expiry time.Time) ShareOption {
+	return func(opts *shareOptions) {
+		opts.expiry = expiry
+	}
+}
+
+// WithShareID tags the share with shareID, so it can be revoked with RevokeShare. It should be random, like a UUID
+func WithShareID(shareID string) ShareOption {
+	return func(opts *shareOptions) {
+		opts.shareID = shareID
+	}
+}
+
+func ShareFile(fileMeta *FileMetadata, tokenStr string, masterKey MasterKey, opts ...ShareOption) (*ViewFileInfo, error) {
+	// its chunks are encrypted with a key derived from the master key, which can't be handed out
+	if fileMeta.ContentAddressed {
+		return nil, fmt.Errorf("file %s is content addressed, so it can't be shared", fileMeta.Id)
+	}
+	options := shareOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	shareToken, err := attenuateShareToken(tokenStr, []string{fileMeta.Id}, nil, options)
 	if err != nil {
 		return nil, err
 	}
 
-	tokenBytes, err := base64.URLEncoding.DecodeString(tokenStr)
+	// files with a data key can be shared without giving away anything about the user's other files
+	fileKey, err := fileEncKey(fileMeta.Id, fileMeta.WrappedDataKey, masterKey)
 	if err != nil {
 		return nil, err
 	}
 
+	return &ViewFileInfo{
+		Id:         fileMeta.Id,
+		Token:      shareToken,
+		FileEncKey: base64.URLEncoding.EncodeToString(fileKey),
+	}, nil
+}
+
+// attenuateShareToken appends a block to tokenStr limiting it to fileIDs, with the access and expiry in options.
+// the ones also in readOnlyFileIDs can only be read, whatever the access
+func attenuateShareToken(tokenStr string, fileIDs []string, readOnlyFileIDs []string, options shareOptions) (string, error) {
+	rights, err := options.access.rights()
+	if err != nil {
+		return "", err
+	}
+
+	allowedFileIDs := biscuit.Set{}
+	for _, fileID := range fileIDs {
+		fileUUID, err := uuid.Parse(fileID)
+		if err != nil {
+			return "", err
+		}
+		allowedFileIDs = append(allowedFileIDs, biscuit.String(fileUUID.String()))
+	}
+
+	tokenBytes, err := base64.URLEncoding.DecodeString(tokenStr)
+	if err != nil {
+		return "", err
+	}
+
 	token, err := biscuit.Unmarshal(tokenBytes)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
 	blockBuilder := token.CreateBlock()
@@ -63,27 +163,42 @@ func ShareFile(fileMeta *FileMetadata, tokenStr string, masterKey MasterKey) (*V
 	// check all is currently unsupported in biscuit-go, gotta use a few check ifs rn
 	check, err := parser.FromStringCheck(`check if rights($rights), right($right), $rights.contains($right)`)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 	err = blockBuilder.AddCheck(check)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
 	check, err = parser.FromStringCheck(`check if allowed_file_ids($allowed_file_ids), file_ids($file_ids), $allowed_file_ids.contains($file_ids)`)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 	err = blockBuilder.AddCheck(check)
 	if err != nil {
-		return nil, err
+		return "", err
+	}
+
+	for _, fileID := range readOnlyFileIDs {
+		fileUUID, err := uuid.Parse(fileID)
+		if err != nil {
+			return "", err
+		}
+		check, err = parser.FromStringCheck(fmt.Sprintf(`check if right("read") or file_ids($file_ids), !$file_ids.contains(%q)`, fileUUID.String()))
+		if err != nil {
+			return "", err
+		}
+		err = blockBuilder.AddCheck(check)
+		if err != nil {
+			return "", err
+		}
 	}
 
 	blockBuilder.AddFact(biscuit.Fact{
 		Predicate: biscuit.Predicate{
 			Name: "rights",
 			IDs: []biscuit.Term{
-				biscuit.Set{biscuit.String("read"), biscuit.String("write")},
+				rights,
 			},
 		},
 	})
@@ -91,110 +206,390 @@ func ShareFile(fileMeta *FileMetadata, tokenStr string, masterKey MasterKey) (*V
 		Predicate: biscuit.Predicate{
 			Name: "allowed_file_ids",
 			IDs: []biscuit.Term{
-				biscuit.Set{biscuit.String(fileUUID.String())},
+				allowedFileIDs,
 			},
 		},
 	})
+
+	if !options.expiry.IsZero() {
+		check, err = parser.FromStringCheck(fmt.Sprintf(`check if time($t), $t <= %s`, options.expiry.UTC().Format(time.RFC3339)))
+		if err != nil {
+			return "", err
+		}
+		err = blockBuilder.AddCheck(check)
+		if err != nil {
+			return "", err
+		}
+	}
+	if options.shareID != "" {
+		blockBuilder.AddFact(biscuit.Fact{
+			Predicate: biscuit.Predicate{
+				Name: "share_id",
+				IDs: []biscuit.Term{
+					biscuit.String(options.shareID),
+				},
+			},
+		})
+	}
 	rng := rand.Reader
 	newToken, err := token.Append(rng, blockBuilder.Build())
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
-	fileKeyBytes := masterKey[:]
-	fileKeyBytes = append(fileKeyBytes, fileUUIDBin...)
-	fileKey := blake3.Sum256(fileKeyBytes)
-
 	serializedToken, err := newToken.Serialize()
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	serializedTokenStr := base64.URLEncoding.EncodeToString(serializedToken)
-
-	return &ViewFileInfo{
-		Id:         fileMeta.Id,
-		Token:      serializedTokenStr,
-		FileEncKey: base64.URLEncoding.EncodeToString(fileKey[:]),
-	}, nil
+	return base64.URLEncoding.EncodeToString(serializedToken), nil
 }
 
+// the number of chunks DownloadFile keeps in flight
+const downloadWindow = 32
+
+// the number of content addressed chunks uploadFile checks with each ChunksUploaded query
+const chunksUploadedBatchSize = 16
+
 type FileInfo struct {
 	Name   string
 	Reader io.Reader
 }
 
-func UploadFile(ctx context.Context, fileInfo *FileInfo, concurrencyLimit int) error {
-	chunks := sync.Map{}
-	var totalSize uint64 = 0
+type uploadOptions struct {
+	contentDefinedChunking bool
+	journalDir             string
+	padded                 bool
+	compressionLevel       CompressionLevel
+}
+
+type UploadOption func(*uploadOptions)
+
+// WithContentDefinedChunking splits the file at content defined boundaries instead of every ChunkSize bytes, and skips uploading chunks the server already has from any of the user's files.
+// chunks may then be shared between files, so they mustn't be deleted along with a single file
+func WithContentDefinedChunking() UploadOption {
+	return func(opts *uploadOptions) {
+		opts.contentDefinedChunking = true
+	}
+}
+
+// WithUploadJournal records the upload's progress in journalDir. If the upload fails, its chunks are kept on the server so it can be continued with ResumeUpload
+func WithUploadJournal(journalDir string) UploadOption {
+	return func(opts *uploadOptions) {
+		opts.journalDir = journalDir
+	}
+}
+
+// WithPadding pads the file's encrypted chunks and metadata to a limited set of sizes, so the server can't tell files apart by how well their chunks compressed.
+// it costs at most ~12% more storage
+func WithPadding() UploadOption {
+	return func(opts *uploadOptions) {
+		opts.padded = true
+	}
+}
+
+// WithCompressionLevel sets how hard chunks are compressed. Chunks that don't look like they'd compress, like ones from JPEGs or zip files, are stored uncompressed at any level
+func WithCompressionLevel(level CompressionLevel) UploadOption {
+	return func(opts *uploadOptions) {
+		opts.compressionLevel = level
+	}
+}
+
+type UploadResult struct {
+	FileMetadata *FileMetadata
+	// how much of the file didn't need to be uploaded, because the server already had those chunks
+	DeduplicatedBytes uint64
+}
+
+func UploadFile(ctx context.Context, fileInfo *FileInfo, concurrencyLimit int, opts ...UploadOption) (*UploadResult, error) {
+	options := uploadOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
 
 	fileID, err := uuid.NewRandom()
 	if err != nil {
-		return err
+		return nil, err
+	}
+	_, wrappedDataKey, err := newDataKey(fileID.String(), MasterKeyFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	var journal *uploadJournal
+	if options.journalDir != "" {
+		journal, err = createUploadJournal(options.journalDir, fileID.String(), fileInfo, options, wrappedDataKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return uploadFile(ctx, fileInfo, concurrencyLimit, fileID.String(), wrappedDataKey, options, journal, map[uint64]*journalChunk{})
+}
+
+// ResumeUpload continues an upload started with WithUploadJournal, see PendingUploads. fileInfo has to read the same source as the original upload from the start.
+// every chunk is read and checked against the journal, and only the ones the server doesn't have are uploaded. ErrSourceChanged is returned if the source doesn't match
+func ResumeUpload(ctx context.Context, journalDir string, fileID string, fileInfo *FileInfo, concurrencyLimit int) (*UploadResult, error) {
+	journal, err := openUploadJournal(journalDir, fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	sourceSize, sourceModTime := sourceStat(fileInfo)
+	if (journal.header.SourceSize != 0 || journal.header.SourceModTime != 0) && (sourceSize != journal.header.SourceSize || sourceModTime != journal.header.SourceModTime) {
+		journal.close()
+		return nil, ErrSourceChanged
 	}
 
+	client := ClientFromContext(ctx)
+	landedChunks := map[uint64]*journalChunk{}
+	if len(journal.chunks) > 0 {
+		chunkIDs := []string{}
+		for _, chunk := range journal.chunks {
+			chunkIDs = append(chunkIDs, chunk.ChunkID)
+		}
+		chunksUploaded, err := ChunksUploaded(client.withContext(ctx), chunkIDs)
+		if err != nil {
+			journal.close()
+			return nil, err
+		}
+		for indice, chunk := range journal.chunks {
+			if chunksUploaded[chunk.ChunkID] {
+				landedChunks[indice] = chunk
+			}
+		}
+	}
+
+	options := uploadOptions{
+		contentDefinedChunking: journal.header.ContentAddressed,
+		journalDir:             journalDir,
+		padded:                 journal.header.Padded,
+		compressionLevel:       journal.header.CompressionLevel,
+	}
+	return uploadFile(ctx, fileInfo, concurrencyLimit, fileID, journal.header.WrappedDataKey, options, journal, landedChunks)
+}
+
+// uploadFile uploads every chunk of a file except for landedChunks, which an earlier attempt already uploaded, then uploads its metadata.
+// wrappedDataKey is empty for uploads journaled before files had data keys
+func uploadFile(ctx context.Context, fileInfo *FileInfo, concurrencyLimit int, fileID string, wrappedDataKey []byte, options uploadOptions, journal *uploadJournal, landedChunks map[uint64]*journalChunk) (*UploadResult, error) {
+	chunks := sync.Map{}
+	chunkSizes := sync.Map{}
+	chunkHashes := sync.Map{}
+	// chunks only this upload refers to, which are deleted again if it fails
+	uploadedChunks := sync.Map{}
+	// content addressed chunks already handled by this upload
+	seenChunks := sync.Map{}
+	var deduplicatedBytes atomic.Uint64
+	var totalSize uint64 = 0
+	var err error
+
 	client := ClientFromContext(ctx)
 	masterKey := MasterKeyFromContext(ctx)
 
-	g := errgroup.Group{}
-	g.SetLimit(100)
-	offset := 0
+	var fileChunker chunker
+	if options.contentDefinedChunking {
+		fileChunker = newContentDefinedChunker(fileInfo.Reader)
+	} else {
+		fileChunker = newFixedSizeChunker(fileInfo.Reader)
+	}
+	chunkAEAD, err := newChunkCodec(masterKey).fileAEAD(fileID, options.contentDefinedChunking, wrappedDataKey)
+	if err != nil {
+		return nil, err
+	}
 
-UploadLoop:
-	for {
-		buf := make([]byte, 1024*1024)
-		n, err := fileInfo.Reader.Read(buf)
-		if err != nil {
-			switch {
-			case errors.Is(err, io.EOF):
-				break UploadLoop
-			default:
-				return err
+	if concurrencyLimit < 1 {
+		concurrencyLimit = 1
+	}
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(concurrencyLimit)
+	// in flight requests are aborted as soon as the upload fails or ctx is cancelled
+	uploadClient := client.withContext(gctx)
+	var indice int64 = 0
+
+	// sendChunk uploads one chunk. onServer is the content addressed chunks the server already had when the chunk's batch was checked
+	sendChunk := func(buf []byte, chunkIndice int64, chunkId string, onServer map[string]bool) error {
+		chunkHash := blake3.Sum256(buf)
+		chunkLen := uint32(len(buf))
+		chunkHashes.Store(uint64(chunkIndice), chunkHash)
+
+		if landedChunk, ok := landedChunks[uint64(chunkIndice)]; ok {
+			if landedChunk.Size != chunkLen || !bytes.Equal(landedChunk.Hash, chunkHash[:]) {
+				return ErrSourceChanged
 			}
+			chunks.Store(uint64(chunkIndice), landedChunk.ChunkID)
+			chunkSizes.Store(uint64(chunkIndice), chunkLen)
+			return nil
 		}
-		buf = buf[:n]
-		totalSize += uint64(n)
 
-		g.Go(func() error {
-			chunkHash := blake3.Sum256(buf)
-			chunkId, err := uuid.NewRandom()
+		if options.contentDefinedChunking {
+			chunks.Store(uint64(chunkIndice), chunkId)
+			chunkSizes.Store(uint64(chunkIndice), chunkLen)
+
+			// identical chunks only need uploading once, whether they're earlier in this file or in another one
+			if _, seen := seenChunks.LoadOrStore(chunkId, struct{}{}); seen {
+				deduplicatedBytes.Add(uint64(len(buf)))
+				return nil
+			}
+			if onServer[chunkId] {
+				deduplicatedBytes.Add(uint64(len(buf)))
+				if journal != nil {
+					return journal.record(&journalChunk{
+						Indice:  uint64(chunkIndice),
+						ChunkID: chunkId,
+						Hash:    chunkHash[:],
+						Size:    chunkLen,
+					})
+				}
+				return nil
+			}
+		} else {
+			chunkUUID, err := uuid.NewRandom()
 			if err != nil {
 				return err
 			}
-			chunkLen := uint32(len(buf))
+			chunkId = chunkUUID.String()
+			chunks.Store(uint64(chunkIndice), chunkId)
+			chunkSizes.Store(uint64(chunkIndice), chunkLen)
+		}
 
-			chunkNonce := make([]byte, 24)
-			// random bytes for chunk nonce
-			_, err = rand.Read(chunkNonce)
-			if err != nil {
-				return err
+		chunkNonce := make([]byte, 24)
+		// random bytes for chunk nonce
+		_, err := rand.Read(chunkNonce)
+		if err != nil {
+			return err
+		}
+		chunkMetadata := &ChunkMetadata{
+			Id:     chunkId,
+			Hash:   chunkHash[:],
+			Size:   chunkLen,
+			Indice: chunkIndice,
+			Nonce:  chunkNonce,
+		}
+		if !options.contentDefinedChunking {
+			chunkMetadata.FormatVersion = chunkFormatBound
+		}
+
+		processecdChunk, err := compressEncryptChunk(buf, chunkMetadata, fileID, chunkAEAD, options.compressionLevel, options.padded)
+		if err != nil {
+			return err
+		}
+		var encChunkMetadata []byte
+		if options.contentDefinedChunking {
+			encChunkMetadata, err = compressEncryptContentAddressedChunkMetadata(chunkMetadata, chunkAEAD, options.padded)
+		} else {
+			encChunkMetadata, err = compressEncryptChunkMetadata(chunkMetadata, chunkAEAD, options.padded)
+		}
+		if err != nil {
+			return err
+		}
+
+		err = uploadChunkWithRetry(gctx, uploadClient, chunkId, fileID, options.contentDefinedChunking, encChunkMetadata, *processecdChunk)
+		if err != nil {
+			return err
+		}
+
+		// a content addressed chunk can be shared with another file being uploaded at the same time, so only this upload's own chunks are cleaned up
+		if !options.contentDefinedChunking {
+			uploadedChunks.Store(chunkId, struct{}{})
+		}
+		if journal != nil {
+			return journal.record(&journalChunk{
+				Indice:  uint64(chunkIndice),
+				ChunkID: chunkId,
+				Nonce:   chunkNonce,
+				Hash:    chunkHash[:],
+				Size:    chunkLen,
+			})
+		}
+		return nil
+	}
+
+	type pendingChunk struct {
+		buf     []byte
+		indice  int64
+		chunkId string
+	}
+	// sendBatch asks the server which of batch's content addressed chunks it already has in one query, then uploads the batch
+	sendBatch := func(batch []pendingChunk) error {
+		var onServer map[string]bool
+		chunkIDs := []string{}
+		for _, chunk := range batch {
+			if chunk.chunkId == "" || slices.Contains(chunkIDs, chunk.chunkId) {
+				continue
 			}
-			chunkMetadata := &ChunkMetadata{
-				Id:     chunkId.String(),
-				Hash:   chunkHash[:],
-				Size:   chunkLen,
-				Indice: int64(offset / (1024 * 1024)),
-				Nonce:  chunkNonce,
+			if _, seen := seenChunks.Load(chunk.chunkId); !seen {
+				chunkIDs = append(chunkIDs, chunk.chunkId)
 			}
-
-			processecdChunk, err := CompressEncryptChunk(buf, chunkMetadata, fileID.String(), masterKey)
+		}
+		if len(chunkIDs) > 0 {
+			var err error
+			onServer, err = ChunksUploaded(uploadClient, chunkIDs)
 			if err != nil {
 				return err
 			}
+		}
 
-			b := backoff.NewExponentialBackOff(backoff.WithMaxElapsedTime(10 * time.Second))
-			err = backoff.Retry(func() error {
-				return UploadChunk(client, chunkMetadata, fileID.String(), *processecdChunk, masterKey)
-			}, b)
+		for _, chunk := range batch {
+			g.Go(func() error {
+				return sendChunk(chunk.buf, chunk.indice, chunk.chunkId, onServer)
+			})
+		}
+		return nil
+	}
 
-			chunks.Store(uint64(chunkMetadata.Indice), chunkMetadata.Id)
-			return nil
-		})
+	batchSize := 1
+	if options.contentDefinedChunking {
+		batchSize = chunksUploadedBatchSize
+	}
+	batch := []pendingChunk{}
+	var readErr error
+	for gctx.Err() == nil {
+		buf, err := fileChunker.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			readErr = err
+			break
+		}
+		totalSize += uint64(len(buf))
+		chunk := pendingChunk{buf: buf, indice: indice}
+		indice++
 
-		offset += 1024
+		if _, landed := landedChunks[uint64(chunk.indice)]; options.contentDefinedChunking && !landed {
+			chunk.chunkId = contentAddressedChunkID(masterKey, buf)
+		}
+		batch = append(batch, chunk)
+		if len(batch) < batchSize {
+			continue
+		}
+		readErr = sendBatch(batch)
+		if readErr != nil {
+			break
+		}
+		batch = []pendingChunk{}
+	}
+	if readErr == nil && len(batch) > 0 && gctx.Err() == nil {
+		readErr = sendBatch(batch)
 	}
 
-	if err := g.Wait(); err != nil {
-		return err
+	err = g.Wait()
+	if err == nil {
+		err = readErr
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err == nil && journal != nil {
+		// the source is shorter than it was when the journal was written
+		for journalIndice := range journal.chunks {
+			if journalIndice >= uint64(indice) {
+				err = ErrSourceChanged
+				break
+			}
+		}
+	}
+	if err != nil {
+		return nil, abortUpload(client, journal, &uploadedChunks, err)
 	}
 
 	chunksFileMetadata := map[uint64]string{}
@@ -202,10 +597,24 @@ UploadLoop:
 		chunksFileMetadata[key.(uint64)] = value.(string)
 		return true
 	})
+	chunkSizesFileMetadata := map[uint64]uint32{}
+	chunkSizes.Range(func(key, value interface{}) bool {
+		chunkSizesFileMetadata[key.(uint64)] = value.(uint32)
+		return true
+	})
+	chunkTreeLeaves := []chunkTreeLeaf{}
+	chunkHashes.Range(func(key, value interface{}) bool {
+		chunkTreeLeaves = append(chunkTreeLeaves, chunkTreeLeaf{
+			indice: key.(uint64),
+			size:   chunkSizesFileMetadata[key.(uint64)],
+			hash:   value.([32]byte),
+		})
+		return true
+	})
 
 	currentUnixUTCTime := time.Now().UTC().Unix()
 	fileMetadata := &FileMetadata{
-		Id:               fileID.String(),
+		Id:               fileID,
 		Chunks:           chunksFileMetadata,
 		FileName:         fileInfo.Name,
 		FileType:         FileType_UNKNOWN,
@@ -213,19 +622,77 @@ UploadLoop:
 		Directory:        []string{},
 		CreateTime:       currentUnixUTCTime,
 		ModificationTime: currentUnixUTCTime,
+		ContentAddressed: options.contentDefinedChunking,
+		ChunkSizes:       chunkSizesFileMetadata,
+		WrappedDataKey:   wrappedDataKey,
+		ChunkTreeHash:    chunkTreeHash(chunkTreeLeaves),
+		Padded:           options.padded,
 	}
-	err = UploadFileMetadata(client, fileMetadata, masterKey)
+	err = UploadFileMetadata(client.withContext(ctx), fileMetadata, masterKey)
 	if err != nil {
+		return nil, abortUpload(client, journal, &uploadedChunks, err)
+	}
+
+	if journal != nil {
+		err = journal.remove()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &UploadResult{
+		FileMetadata:      fileMetadata,
+		DeduplicatedBytes: deduplicatedBytes.Load(),
+	}, nil
+}
+
+// uploadChunkWithRetry uploads a chunk, retrying failures to send it but not errors the server reported.
+// the server won't replace a chunk, so a content addressed one that another upload of the same data got there first with counts as uploaded
+func uploadChunkWithRetry(ctx context.Context, cli FileServerClient, chunkId string, fileId string, contentAddressed bool, encChunkMetadata []byte, chunk EncryptedCompressedChunk) error {
+	b := backoff.NewExponentialBackOff(backoff.WithMaxElapsedTime(10 * time.Second))
+	err := backoff.Retry(func() error {
+		err := uploadChunk(cli, chunkId, fileId, encChunkMetadata, chunk)
+		var srvErr *serverError
+		if errors.As(err, &srvErr) {
+			return backoff.Permanent(err)
+		}
 		return err
+	}, backoff.WithContext(b, ctx))
+
+	var srvErr *serverError
+	if contentAddressed && errors.As(err, &srvErr) {
+		chunksUploaded, uploadedErr := ChunksUploaded(cli, []string{chunkId})
+		if uploadedErr == nil && chunksUploaded[chunkId] {
+			return nil
+		}
 	}
+	return err
+}
 
-	return nil
+// abortUpload cleans up after a failed upload. Without a journal it can never be resumed, so the chunks it uploaded are deleted so they don't count against the user's storage forever.
+// content addressed chunks are left for garbage collection, since another upload may already refer to them
+func abortUpload(client FileServerClient, journal *uploadJournal, uploadedChunks *sync.Map, err error) error {
+	if journal != nil {
+		return errors.Join(err, journal.close())
+	}
+
+	chunkIDs := []string{}
+	uploadedChunks.Range(func(key, value interface{}) bool {
+		chunkIDs = append(chunkIDs, key.(string))
+		return true
+	})
+	if len(chunkIDs) == 0 {
+		return err
+	}
+
+	return errors.Join(err, DeleteChunks(client, chunkIDs))
 }
 
 func DownloadFile(ctx context.Context, fileMeta *FileMetadata, fileWriter io.Writer, token string) error {
-	client := ClientFromContext(ctx)
-	masterKey := MasterKeyFromContext(ctx)
+	return downloadFile(ctx, ClientFromContext(ctx), newChunkCodec(MasterKeyFromContext(ctx)), fileMeta, fileWriter, token)
+}
 
+func downloadFile(ctx context.Context, client FileServerClient, codec *chunkCodec, fileMeta *FileMetadata, fileWriter io.Writer, token string) error {
 	chunkIndices := []uint64{}
 
 	for chunkIndice := range fileMeta.Chunks {
@@ -233,72 +700,198 @@ func DownloadFile(ctx context.Context, fileMeta *FileMetadata, fileWriter io.Wri
 	}
 	sort.Slice(chunkIndices, func(i, j int) bool { return chunkIndices[i] < chunkIndices[j] })
 
-	g := errgroup.Group{}
-	g.SetLimit(100)
-	for _, indice := range chunkIndices {
-		indice := indice
-		chunkId := fileMeta.Chunks[indice]
-		chunk, err := DownloadChunk(client, DownloadChunkArgs{
-			ChunkID: chunkId,
-			FileID:  fileMeta.Id,
-			Token:   token,
-		}, masterKey)
-		if err != nil {
-			return err
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	g, ctx := errgroup.WithContext(ctx)
+	downloadClient := client.withContext(ctx)
+
+	// chunks are downloaded concurrently, but handed to the writer in order through pending.
+	// a download is only started once its slot fits in pending, so at most downloadWindow chunks are held in memory at once: the ones in pending, and the one being waited for
+	pending := make(chan chan *downloadedChunk, downloadWindow-1)
+	g.Go(func() error {
+		defer close(pending)
+		for _, indice := range chunkIndices {
+			chunkId := fileMeta.Chunks[indice]
+			result := make(chan *downloadedChunk, 1)
+			select {
+			case pending <- result:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+
+			g.Go(func() error {
+				chunk, err := codec.downloadChunk(downloadClient, DownloadChunkArgs{
+					ChunkID:          chunkId,
+					FileID:           fileMeta.Id,
+					Indice:           indice,
+					Size:             fileMeta.ChunkSizes[indice],
+					Token:            token,
+					ContentAddressed: fileMeta.ContentAddressed,
+					WrappedDataKey:   fileMeta.WrappedDataKey,
+				})
+				if err != nil {
+					return err
+				}
+				result <- &downloadedChunk{
+					data: chunk,
+					leaf: chunkTreeLeaf{
+						indice: indice,
+						size:   uint32(len(chunk)),
+						hash:   blake3.Sum256(chunk),
+					},
+				}
+				return nil
+			})
 		}
+		return nil
+	})
 
-		_, err = fileWriter.Write(chunk)
-		if err != nil {
-			return err
+	chunkTreeLeaves := []chunkTreeLeaf{}
+	var written uint64 = 0
+	for result := range pending {
+		select {
+		case chunk := <-result:
+			_, err := fileWriter.Write(chunk.data)
+			if err != nil {
+				cancel()
+				g.Wait()
+				return err
+			}
+			chunkTreeLeaves = append(chunkTreeLeaves, chunk.leaf)
+			written += uint64(len(chunk.data))
+		case <-ctx.Done():
+			if err := g.Wait(); err != nil {
+				return err
+			}
+			return ctx.Err()
 		}
 	}
-	if err := g.Wait(); err != nil {
+
+	err := g.Wait()
+	if err != nil {
 		return err
 	}
+	return checkChunkTree(fileMeta, chunkTreeLeaves, written)
+}
+
+type downloadedChunk struct {
+	data []byte
+	leaf chunkTreeLeaf
+}
 
+// checkChunkTree checks that the chunks of fileMeta that were downloaded are exactly the ones it was uploaded with.
+// files uploaded before FileMetadata.ChunkTreeHash existed can't be checked
+func checkChunkTree(fileMeta *FileMetadata, chunkTreeLeaves []chunkTreeLeaf, size uint64) error {
+	if len(fileMeta.ChunkTreeHash) == 0 {
+		return nil
+	}
+	if !bytes.Equal(chunkTreeHash(chunkTreeLeaves), fileMeta.ChunkTreeHash) {
+		return ErrChunkTreeMismatch
+	}
+	if size != fileMeta.FileSize {
+		return fmt.Errorf("file is %d bytes, expected %d", size, fileMeta.FileSize)
+	}
 	return nil
 }
 
-func EncodeViewFileInfo(view *ViewFileInfo) (string, error) {
-	bin, err := proto.Marshal(view)
+// VerifyFile downloads every chunk of a file without keeping them, checking each one's hash and that together they match FileMetadata.ChunkTreeHash
+func VerifyFile(ctx context.Context, fileMeta *FileMetadata, token string) error {
+	return DownloadFile(ctx, fileMeta, io.Discard, token)
+}
+
+// OpenFile streams the plaintext of a file in order, downloading chunks ahead of the reader in the background like DownloadFile does.
+// errors downloading a chunk are returned from Read. Closing the reader stops the background downloads, after which Read and Close return fs.ErrClosed
+func OpenFile(ctx context.Context, fileMeta *FileMetadata) (io.ReadCloser, error) {
+	return openFile(ctx, ClientFromContext(ctx), newChunkCodec(MasterKeyFromContext(ctx)), fileMeta), nil
+}
+
+func openFile(ctx context.Context, client FileServerClient, codec *chunkCodec, fileMeta *FileMetadata) io.ReadCloser {
+	ctx, cancel := context.WithCancel(ctx)
+	pipeReader, pipeWriter := io.Pipe()
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		err := downloadFile(ctx, client, codec, fileMeta, pipeWriter, "")
+		pipeWriter.CloseWithError(err)
+	}()
+
+	return &fileReader{
+		PipeReader: pipeReader,
+		cancel:     cancel,
+		done:       done,
+	}
+}
+
+// OpenSharedFile opens a file shared with ShareFile, using only what's in view: the share's token for the server, and the file's key to decrypt it.
+// it returns the file's metadata, and streams its plaintext like OpenFile. Content addressed files can't be opened this way, their chunks aren't encrypted with the file's key
+func OpenSharedFile(cli FileServerClient, view *ViewFileInfo) (*FileMetadata, io.ReadCloser, error) {
+	fileKey, err := base64.URLEncoding.DecodeString(view.FileEncKey)
 	if err != nil {
-		return "", err
+		return nil, nil, fmt.Errorf("error decoding shared file key: %w", err)
+	}
+	codec, err := newSharedChunkCodec(map[string][]byte{view.Id: fileKey})
+	if err != nil {
+		return nil, nil, err
 	}
 
-	zstdEncoder, err := zstd.NewWriter(nil)
+	cli = cli.setToken(view.Token)
+	encFileMeta, err := downloadEncryptedFileMetadata(cli, view.Id)
 	if err != nil {
-		return "", err
+		return nil, nil, err
+	}
+	if encFileMeta.Id != view.Id {
+		return nil, nil, fmt.Errorf("got metadata for file %s, expected %s", encFileMeta.Id, view.Id)
+	}
+	fileMeta, err := decryptFileMetadataWithFileKey(encFileMeta, fileKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	if fileMeta.ContentAddressed {
+		return nil, nil, fmt.Errorf("file %s is content addressed, so it can't be opened from a share", fileMeta.Id)
 	}
-	defer zstdEncoder.Close()
 
-	compressedViewBytes := zstdEncoder.EncodeAll(bin, nil)
-	viewInfoB64 := base64.URLEncoding.EncodeToString(compressedViewBytes)
+	return fileMeta, openFile(context.Background(), cli, codec, fileMeta), nil
+}
 
-	return viewInfoB64, nil
+type fileReader struct {
+	*io.PipeReader
+	cancel context.CancelFunc
+	done   chan struct{}
+	closed atomic.Bool
 }
 
-func DecodeViewFileInfoB64(b64 string) (*ViewFileInfo, error) {
-	compressedViewInfoBin, err := base64.URLEncoding.DecodeString(b64)
-	if err != nil {
-		return nil, err
+func (r *fileReader) Read(p []byte) (int, error) {
+	n, err := r.PipeReader.Read(p)
+	// the pipe's own error for reading after Close
+	if err == io.ErrClosedPipe {
+		err = fs.ErrClosed
 	}
+	return n, err
+}
 
-	zstdDecoder, err := zstd.NewReader(nil)
-	if err != nil {
-		return nil, err
+func (r *fileReader) Close() error {
+	if r.closed.Swap(true) {
+		return fs.ErrClosed
 	}
-	defer zstdDecoder.Close()
+	r.cancel()
+	err := r.PipeReader.Close()
+	// wait for the download to actually stop, so nothing is still using the client once Close returns
+	<-r.done
+	return err
+}
 
-	viewInfoBytes, err := zstdDecoder.DecodeAll(compressedViewInfoBin, nil)
-	if err != nil {
-		return nil, err
-	}
+// EncodeViewFileInfo zstd compresses and base64 encodes view, the same way ShareLink.URL puts it in a link
+func EncodeViewFileInfo(view *ViewFileInfo) (string, error) {
+	return encodeShareLinkMessage(view)
+}
 
-	var viewFileInfo ViewFileInfo
-	err = proto.Unmarshal(viewInfoBytes, &viewFileInfo)
+// DecodeViewFileInfoB64 decodes a view from EncodeViewFileInfo. Errors wrap ErrShareLinkMalformed or ErrShareLinkTruncated, like ParseShareLink's
+func DecodeViewFileInfoB64(b64 string) (*ViewFileInfo, error) {
+	var view ViewFileInfo
+	err := decodeShareLinkMessage(b64, &view)
 	if err != nil {
 		return nil, err
 	}
-
-	return &viewFileInfo, nil
+	return &view, nil
 }
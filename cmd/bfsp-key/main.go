@@ -0,0 +1,225 @@
+// Command bfsp-key exports and imports the master key in the client config file as a recovery phrase, or as shares any threshold of which can recover it.
+//
+//	bfsp-key export
+//	bfsp-key import < phrase.txt
+//	bfsp-key split -n 5 -threshold 3
+//	bfsp-key combine < shares.txt
+//
+// export prints the phrase, then asks for it to be typed back so it's known to have been written down correctly.
+// import and combine only save a key once its checksums match, and won't replace a different key that's already in the config file without -force.
+// replacing a key drops the key derivation saved with the old one, which can't derive the new key
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/BillysBigFileServer/bfsp-go"
+	"github.com/BillysBigFileServer/bfsp-go/config"
+	"github.com/BillysBigFileServer/bfsp-go/shamir"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: bfsp-key export|import|split|combine")
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "export":
+		exportCmd(os.Args[2:])
+	case "import":
+		importCmd(os.Args[2:])
+	case "split":
+		splitCmd(os.Args[2:])
+	case "combine":
+		combineCmd(os.Args[2:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q, expected export, import, split or combine\n", os.Args[1])
+		os.Exit(2)
+	}
+}
+
+func exportCmd(args []string) {
+	flags := flag.NewFlagSet("bfsp-key export", flag.ExitOnError)
+	noVerify := flags.Bool("no-verify", false, "don't ask for the phrase to be typed back")
+	flags.Parse(args)
+
+	masterKey := configMasterKey()
+	mnemonic, err := bfsp.MasterKeyToMnemonic(masterKey)
+	if err != nil {
+		log.Fatalf("error creating recovery phrase: %s", err)
+	}
+	fmt.Println(mnemonic)
+	if *noVerify {
+		return
+	}
+
+	fmt.Fprintln(os.Stderr, "\nwrite the phrase down, anyone with it can read your files. Then type it back to check it:")
+	for {
+		typedMasterKey, err := bfsp.MasterKeyFromMnemonic(readLine())
+		if err == nil && bytes.Equal(typedMasterKey, masterKey) {
+			fmt.Fprintln(os.Stderr, "the phrase is correct")
+			return
+		}
+		if err == nil {
+			err = fmt.Errorf("it's a valid phrase, but for a different key")
+		}
+		fmt.Fprintf(os.Stderr, "that doesn't match: %s. Try again:\n", err)
+	}
+}
+
+func importCmd(args []string) {
+	flags := flag.NewFlagSet("bfsp-key import", flag.ExitOnError)
+	force := flags.Bool("force", false, "replace a different master key that's already in the config file")
+	flags.Parse(args)
+
+	fmt.Fprintln(os.Stderr, "enter your recovery phrase:")
+	masterKey, err := bfsp.MasterKeyFromMnemonic(readLine())
+	if err != nil {
+		log.Fatalf("error reading recovery phrase: %s", err)
+	}
+
+	saveMasterKey(func(cfg *config.Config) error {
+		cfg.SetEncryptionKey(masterKey)
+		return nil
+	}, *force)
+}
+
+func splitCmd(args []string) {
+	flags := flag.NewFlagSet("bfsp-key split", flag.ExitOnError)
+	n := flags.Int("n", 5, "number of shares")
+	threshold := flags.Int("threshold", 3, "number of shares needed to recover the key")
+	flags.Parse(args)
+
+	masterKey := configMasterKey()
+	shares, err := shamir.Split(masterKey, *n, *threshold)
+	if err != nil {
+		log.Fatalf("error splitting master key: %s", err)
+	}
+	for _, share := range shares {
+		encodedShare, err := shamir.EncodeShare(share)
+		if err != nil {
+			log.Fatalf("error encoding share: %s", err)
+		}
+		fmt.Println(encodedShare)
+	}
+	fmt.Fprintf(os.Stderr, "\ngive each share to a different person, any %d of them can recover your files\n", *threshold)
+}
+
+func combineCmd(args []string) {
+	flags := flag.NewFlagSet("bfsp-key combine", flag.ExitOnError)
+	force := flags.Bool("force", false, "replace a different master key that's already in the config file")
+	flags.Parse(args)
+
+	fmt.Fprintln(os.Stderr, "enter the shares, one per line, then an empty line:")
+	encodedShares := []string{}
+	for {
+		line := readLine()
+		if line == "" {
+			break
+		}
+		encodedShares = append(encodedShares, line)
+	}
+
+	saveMasterKey(func(cfg *config.Config) error {
+		return shamir.RecoverToConfig(cfg, encodedShares)
+	}, *force)
+}
+
+// configMasterKey returns the master key in the config file
+func configMasterKey() bfsp.MasterKey {
+	configFile, cfg, err := openConfig()
+	if err != nil {
+		log.Fatalf("error reading config: %s", err)
+	}
+	configFile.Close()
+	if cfg.EncryptionKey == "" {
+		log.Fatal("there's no master key in the config file")
+	}
+	masterKey, err := cfg.EncryptionKeyBytes()
+	if err != nil {
+		log.Fatalf("error reading master key: %s", err)
+	}
+	return masterKey
+}
+
+// saveMasterKey sets the master key in the config file with setKey, unless there's already a different one and force isn't set
+func saveMasterKey(setKey func(cfg *config.Config) error, force bool) {
+	configFile, cfg, err := openConfig()
+	if err != nil {
+		log.Fatalf("error reading config: %s", err)
+	}
+	defer configFile.Close()
+
+	err = replaceMasterKey(cfg, setKey, force)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = config.WriteConfigToFile(configFile, cfg)
+	if err != nil {
+		log.Fatalf("error writing config: %s", err)
+	}
+	fmt.Fprintln(os.Stderr, "master key saved")
+}
+
+var errDifferentMasterKey = errors.New("the config file already has a different master key, pass -force to replace it")
+
+// replaceMasterKey sets the master key in cfg with setKey, unless there's already a different one and force isn't set.
+// the key derivation is cleared when the key changes, since it was for the old one
+func replaceMasterKey(cfg *config.Config, setKey func(cfg *config.Config) error, force bool) error {
+	existingKey := cfg.EncryptionKey
+	err := setKey(cfg)
+	if err != nil {
+		return fmt.Errorf("error recovering master key: %w", err)
+	}
+	if existingKey == cfg.EncryptionKey {
+		return nil
+	}
+	if existingKey != "" && !force {
+		return errDifferentMasterKey
+	}
+	cfg.KeyDerivation = ""
+	return nil
+}
+
+// openConfig opens the default config file, which is empty if it was just created
+func openConfig() (*os.File, *config.Config, error) {
+	configFile, err := config.OpenDefaultConfigFile()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	configStat, err := configFile.Stat()
+	if err != nil {
+		configFile.Close()
+		return nil, nil, err
+	}
+	if configStat.Size() == 0 {
+		return configFile, &config.Config{}, nil
+	}
+
+	cfg, err := config.ReadConfig(configFile)
+	if err != nil {
+		configFile.Close()
+		return nil, nil, err
+	}
+	return configFile, cfg, nil
+}
+
+var stdin = bufio.NewReader(os.Stdin)
+
+func readLine() string {
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		log.Fatalf("error reading stdin: %s", err)
+	}
+	return strings.TrimSpace(line)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/BillysBigFileServer/bfsp-go"
+	"github.com/BillysBigFileServer/bfsp-go/config"
+)
+
+func TestReplaceMasterKey(t *testing.T) {
+	var oldCfg config.Config
+	oldKey, err := bfsp.SetUpMasterKey(&oldCfg, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldCfg.SetEncryptionKey(oldKey)
+	newKey := bytes.Repeat([]byte{1}, len(oldKey))
+	setNewKey := func(cfg *config.Config) error {
+		cfg.SetEncryptionKey(newKey)
+		return nil
+	}
+
+	tests := map[string]struct {
+		setKey            func(cfg *config.Config) error
+		force             bool
+		wantErr           error
+		wantKey           []byte
+		keepKeyDerivation bool
+	}{
+		"same key": {
+			setKey:            func(cfg *config.Config) error { cfg.SetEncryptionKey(oldKey); return nil },
+			wantKey:           oldKey,
+			keepKeyDerivation: true,
+		},
+		"different key": {
+			setKey:  setNewKey,
+			wantErr: errDifferentMasterKey,
+		},
+		"different key with force": {
+			setKey:  setNewKey,
+			force:   true,
+			wantKey: newKey,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := oldCfg
+			err := replaceMasterKey(&cfg, test.setKey, test.force)
+			if err != test.wantErr {
+				t.Fatalf("got error %v, expected %v", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			key, err := cfg.EncryptionKeyBytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(key, test.wantKey) {
+				t.Fatal("saved the wrong key")
+			}
+			if test.keepKeyDerivation && cfg.KeyDerivation != oldCfg.KeyDerivation {
+				t.Fatal("the key derivation changed with the key")
+			}
+			if !test.keepKeyDerivation && cfg.KeyDerivation != "" {
+				t.Fatal("the old key's derivation was kept with a new key")
+			}
+		})
+	}
+
+	// a key in an empty config has no derivation to keep
+	cfg := config.Config{}
+	err = replaceMasterKey(&cfg, setNewKey, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.KeyDerivation != "" {
+		t.Fatal("set a key derivation for an imported key")
+	}
+}
@@ -0,0 +1,119 @@
+// Command bfsp-server runs a self-hosted file server.
+//
+//	bfsp-server -addr :9998 -storage ./data -root-key-file ./root_key
+//	bfsp-server token -root-key-file ./root_key -user 1
+//
+// The root key file holds a hex encoded ed25519 private key, and is created on first run if it doesn't exist.
+// Point clients at the server with FILE_SERVER_BASE_URL and FILE_SERVER_HTTPS=false
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/BillysBigFileServer/bfsp-go/server"
+)
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "token" {
+		tokenCmd(os.Args[2:])
+		return
+	}
+	serveCmd(os.Args[1:])
+}
+
+func serveCmd(args []string) {
+	flags := flag.NewFlagSet("bfsp-server", flag.ExitOnError)
+	addr := flags.String("addr", ":9998", "address to listen on")
+	storageDir := flags.String("storage", "", "directory to store files in, or empty to keep everything in memory")
+	rootKeyFile := flags.String("root-key-file", "root_key", "file containing the hex encoded ed25519 root key tokens are signed with")
+	storageCap := flags.Uint64("storage-cap", server.DefaultStorageCap, "maximum number of bytes each user can store")
+	flags.Parse(args)
+
+	rootKey, err := loadOrCreateRootKey(*rootKeyFile)
+	if err != nil {
+		log.Fatalf("error loading root key: %s", err)
+	}
+
+	var storage server.Storage
+	if *storageDir == "" {
+		log.Println("storing files in memory, they'll be gone when the server stops")
+		storage = server.NewMemoryStorage()
+	} else {
+		storage, err = server.NewDiskStorage(*storageDir)
+		if err != nil {
+			log.Fatalf("error opening storage: %s", err)
+		}
+	}
+
+	srv := server.New(storage, rootKey.Public().(ed25519.PublicKey))
+	srv.StorageCap = *storageCap
+
+	httpServer := &http.Server{
+		Addr:              *addr,
+		Handler:           srv,
+		ReadHeaderTimeout: 10 * time.Second,
+		// long enough to upload the biggest message over a slow connection
+		ReadTimeout:  2 * time.Minute,
+		WriteTimeout: 2 * time.Minute,
+		IdleTimeout:  2 * time.Minute,
+	}
+	log.Printf("listening on %s", *addr)
+	log.Fatal(httpServer.ListenAndServe())
+}
+
+func tokenCmd(args []string) {
+	flags := flag.NewFlagSet("bfsp-server token", flag.ExitOnError)
+	rootKeyFile := flags.String("root-key-file", "root_key", "file containing the hex encoded ed25519 root key tokens are signed with")
+	userID := flags.Int64("user", 1, "id of the user the token is for")
+	flags.Parse(args)
+
+	rootKey, err := loadOrCreateRootKey(*rootKeyFile)
+	if err != nil {
+		log.Fatalf("error loading root key: %s", err)
+	}
+
+	token, err := server.NewToken(rootKey, *userID)
+	if err != nil {
+		log.Fatalf("error creating token: %s", err)
+	}
+	fmt.Println(token)
+}
+
+func loadOrCreateRootKey(path string) (ed25519.PrivateKey, error) {
+	keyHex, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		_, rootKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		err = os.WriteFile(path, []byte(hex.EncodeToString(rootKey)+"\n"), 0600)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("created new root key at %s", path)
+		return rootKey, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	keyBin, err := hex.DecodeString(strings.TrimSpace(string(keyHex)))
+	if err != nil {
+		return nil, err
+	}
+	if len(keyBin) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("root key must be %d bytes, got %d", ed25519.PrivateKeySize, len(keyBin))
+	}
+	return ed25519.PrivateKey(keyBin), nil
+}
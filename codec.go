@@ -0,0 +1,255 @@
+package bfsp
+
+import (
+	"crypto/cipher"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// CompressionLevel is how hard chunks are compressed
+type CompressionLevel int
+
+const (
+	CompressionDefault CompressionLevel = iota
+	CompressionFastest
+	CompressionBetter
+	CompressionBest
+	// chunks are stored uncompressed
+	CompressionNone
+)
+
+func (level CompressionLevel) zstdLevel() zstd.EncoderLevel {
+	switch level {
+	case CompressionFastest:
+		return zstd.SpeedFastest
+	case CompressionBetter:
+		return zstd.SpeedBetterCompression
+	case CompressionBest:
+		return zstd.SpeedBestCompression
+	default:
+		return zstd.SpeedDefault
+	}
+}
+
+// zstd encoders and decoders are expensive to create, and only ever used through EncodeAll and DecodeAll here, so they're pooled instead of created for every chunk.
+// with a concurrency of 1 they don't start any goroutines, so ones the pool drops don't need to be closed
+var (
+	zstdEncoderPools [CompressionNone]sync.Pool
+	zstdDecoderPool  sync.Pool
+)
+
+func getZstdEncoder(level CompressionLevel) (*zstd.Encoder, error) {
+	if level < CompressionDefault || level >= CompressionNone {
+		return nil, fmt.Errorf("can't compress at level %d", level)
+	}
+	if zstdEncoder, ok := zstdEncoderPools[level].Get().(*zstd.Encoder); ok {
+		return zstdEncoder, nil
+	}
+	return zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1), zstd.WithEncoderLevel(level.zstdLevel()))
+}
+
+func putZstdEncoder(level CompressionLevel, zstdEncoder *zstd.Encoder) {
+	zstdEncoderPools[level].Put(zstdEncoder)
+}
+
+func getZstdDecoder() (*zstd.Decoder, error) {
+	if zstdDecoder, ok := zstdDecoderPool.Get().(*zstd.Decoder); ok {
+		return zstdDecoder, nil
+	}
+	return zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+}
+
+func putZstdDecoder(zstdDecoder *zstd.Decoder) {
+	zstdDecoderPool.Put(zstdDecoder)
+}
+
+// compressBufferPool holds the buffers chunks are compressed into before they're sealed
+var compressBufferPool sync.Pool
+
+// buffers bigger than this aren't pooled, so one huge chunk doesn't stay in memory forever
+const maxPooledBufferSize = 2 * cdcMaxSize
+
+func getCompressBuffer() []byte {
+	if buf, ok := compressBufferPool.Get().(*[]byte); ok {
+		return (*buf)[:0]
+	}
+	return make([]byte, 0, ChunkSize)
+}
+
+func putCompressBuffer(buf []byte) {
+	if cap(buf) > maxPooledBufferSize {
+		return
+	}
+	buf = buf[:0]
+	compressBufferPool.Put(&buf)
+}
+
+// compress zstd compresses b at level, padding it if pad is set. The result is in a pooled buffer, which should be handed back with putCompressBuffer once it's been sealed
+func compress(b []byte, level CompressionLevel, pad bool) ([]byte, error) {
+	zstdEncoder, err := getZstdEncoder(level)
+	if err != nil {
+		return nil, err
+	}
+	defer putZstdEncoder(level, zstdEncoder)
+
+	compressed := zstdEncoder.EncodeAll(b, getCompressBuffer())
+	if pad {
+		compressed = padCompressed(compressed)
+	}
+	return compressed, nil
+}
+
+// compressChunk compresses a chunk at level, unless it doesn't look like it would compress. Then it's copied as is, and uncompressed is true.
+// either way the result is padded if pad is set, and is in a pooled buffer like compress's
+func compressChunk(chunkBytes []byte, level CompressionLevel, pad bool) (compressed []byte, uncompressed bool, err error) {
+	if level != CompressionNone && compressible(chunkBytes) {
+		compressed, err = compress(chunkBytes, level, false)
+		if err != nil {
+			return nil, false, err
+		}
+		// the sample can be wrong about the rest of the chunk. Padding is added after choosing, so it doesn't push a chunk that did compress over its raw size
+		if len(compressed) < len(chunkBytes) {
+			if pad {
+				compressed = padCompressed(compressed)
+			}
+			return compressed, false, nil
+		}
+		putCompressBuffer(compressed)
+	}
+
+	stored := append(getCompressBuffer(), chunkBytes...)
+	if pad {
+		stored = padUncompressed(stored)
+	}
+	return stored, true, nil
+}
+
+// compressed formats that are common enough to be worth recognizing by their header, which saves compressing a sample of them.
+// formats that are only sometimes compressed, like PDFs, are left to the sample
+var compressedContentTypes = map[string]bool{
+	"image/jpeg":                   true,
+	"image/png":                    true,
+	"image/gif":                    true,
+	"image/webp":                   true,
+	"video/mp4":                    true,
+	"video/webm":                   true,
+	"audio/mpeg":                   true,
+	"audio/ogg":                    true,
+	"application/zip":              true,
+	"application/x-gzip":           true,
+	"application/x-rar-compressed": true,
+}
+
+// chunks are only compressed if a sample of them compresses to less than this fraction of its size. Saving any less isn't worth the cpu time on every download
+const (
+	compressibleSampleSize = 64 * 1024
+	compressibleRatio      = 0.95
+)
+
+// compressible guesses whether chunkBytes is worth compressing, by its header if it's the start of a known compressed format, otherwise by compressing a sample from the middle of it as fast as possible
+func compressible(chunkBytes []byte) bool {
+	if compressedContentTypes[http.DetectContentType(chunkBytes)] {
+		return false
+	}
+	if len(chunkBytes) <= compressibleSampleSize {
+		return true
+	}
+
+	sampleStart := (len(chunkBytes) - compressibleSampleSize) / 2
+	sample := chunkBytes[sampleStart : sampleStart+compressibleSampleSize]
+	compressedSample, err := compress(sample, CompressionFastest, false)
+	if err != nil {
+		return true
+	}
+	defer putCompressBuffer(compressedSample)
+
+	return float64(len(compressedSample)) < compressibleRatio*float64(len(sample))
+}
+
+// decompress decompresses zstd compressed b into dst, which is grown if it's too small
+func decompress(b []byte, dst []byte) ([]byte, error) {
+	zstdDecoder, err := getZstdDecoder()
+	if err != nil {
+		return nil, err
+	}
+	defer putZstdDecoder(zstdDecoder)
+
+	return zstdDecoder.DecodeAll(b, dst)
+}
+
+// chunkCodec encrypts and decrypts the chunks of files under one master key.
+// it caches the AEAD of every file it's been used with, so a file's key is only derived or unwrapped once no matter how many chunks it has
+type chunkCodec struct {
+	masterKey MasterKey
+
+	mu    sync.Mutex
+	aeads map[string]cipher.AEAD
+}
+
+// content addressed chunks share one key, so they share one cache entry. File ids are UUIDs, so this can't collide with one
+const contentAddressedCodecKey = "content addressed"
+
+func newChunkCodec(masterKey MasterKey) *chunkCodec {
+	return &chunkCodec{
+		masterKey: masterKey,
+		aeads:     map[string]cipher.AEAD{},
+	}
+}
+
+// newSharedChunkCodec creates a chunkCodec for just the files in fileKeys, from their keys rather than the master key. It's what a share's recipient has
+func newSharedChunkCodec(fileKeys map[string][]byte) (*chunkCodec, error) {
+	aeads := map[string]cipher.AEAD{}
+	for fileId, fileKey := range fileKeys {
+		aead, err := chacha20poly1305.NewX(fileKey)
+		if err != nil {
+			return nil, err
+		}
+		aeads[fileId] = aead
+	}
+	return &chunkCodec{
+		aeads: aeads,
+	}, nil
+}
+
+// fileAEAD returns the AEAD the chunks of a file are encrypted with
+func (c *chunkCodec) fileAEAD(fileId string, contentAddressed bool, wrappedDataKey []byte) (cipher.AEAD, error) {
+	cacheKey := fileId
+	if contentAddressed {
+		cacheKey = contentAddressedCodecKey
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if aead, ok := c.aeads[cacheKey]; ok {
+		return aead, nil
+	}
+	if c.masterKey == nil {
+		return nil, fmt.Errorf("no key for the chunks of file %s", fileId)
+	}
+
+	var key []byte
+	if contentAddressed {
+		key = deriveContentAddressedKey(c.masterKey)
+	} else {
+		var err error
+		key, err = fileEncKey(fileId, wrappedDataKey, c.masterKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+	aead, err := chacha20poly1305.NewX(key)
+	if err != nil {
+		return nil, err
+	}
+	c.aeads[cacheKey] = aead
+	return aead, nil
+}
+
+// chunkAEAD returns the AEAD the chunks of fileMeta are encrypted with
+func (c *chunkCodec) chunkAEAD(fileMeta *FileMetadata) (cipher.AEAD, error) {
+	return c.fileAEAD(fileMeta.Id, fileMeta.ContentAddressed, fileMeta.WrappedDataKey)
+}
@@ -0,0 +1,111 @@
+package bfsp
+
+import (
+	"bytes"
+	"crypto/rand"
+	mathrand "math/rand"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// textChunk is ChunkSize bytes of made up words, which compress well but differently at each level
+func textChunk() []byte {
+	words := strings.Fields("the quick brown fox jumps over lazy dog chunk file server bytes encrypt upload download share")
+	rng := mathrand.New(mathrand.NewSource(1))
+	var text bytes.Buffer
+	for text.Len() < ChunkSize {
+		text.WriteString(words[rng.Intn(len(words))])
+		text.WriteByte(' ')
+	}
+	return text.Bytes()[:ChunkSize]
+}
+
+func TestCompressEncryptChunk(t *testing.T) {
+	random := make([]byte, ChunkSize)
+	_, err := rand.Read(random)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := textChunk()
+
+	tests := map[string]struct {
+		chunk        []byte
+		level        CompressionLevel
+		uncompressed bool
+	}{
+		"incompressible":   {random, CompressionDefault, true},
+		"compressible":     {text, CompressionDefault, false},
+		"compression none": {text, CompressionNone, true},
+		// PDFs are often compressed inside, but not always, so they aren't skipped by their header
+		"uncompressed pdf": {append([]byte("%PDF-1.7\n"), text[9:]...), CompressionDefault, false},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			aead, err := chacha20poly1305.NewX(bytes.Repeat([]byte{1}, chacha20poly1305.KeySize))
+			if err != nil {
+				t.Fatal(err)
+			}
+			fileID := uuid.NewString()
+			chunkMeta := &ChunkMetadata{
+				Id:            uuid.NewString(),
+				Size:          uint32(len(test.chunk)),
+				Nonce:         bytes.Repeat([]byte{2}, chacha20poly1305.NonceSizeX),
+				FormatVersion: chunkFormatBound,
+			}
+
+			encChunk, err := compressEncryptChunk(test.chunk, chunkMeta, fileID, aead, test.level, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if chunkMeta.Uncompressed != test.uncompressed {
+				t.Fatalf("chunk was stored with Uncompressed = %t", chunkMeta.Uncompressed)
+			}
+			if test.uncompressed && len(encChunk.chunk) != len(test.chunk)+aead.Overhead() {
+				t.Fatalf("uncompressed chunk is %d bytes encrypted, expected %d", len(encChunk.chunk), len(test.chunk)+aead.Overhead())
+			}
+			if !test.uncompressed && len(encChunk.chunk) >= len(test.chunk) {
+				t.Fatalf("compressed chunk is %d bytes encrypted, %d raw", len(encChunk.chunk), len(test.chunk))
+			}
+
+			additionalData, err := chunkAdditionalData(chunkMeta.FormatVersion, fileID, chunkMeta.Id, 0, chunkMeta.Size)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stored, err := aead.Open(nil, chunkMeta.Nonce, encChunk.chunk, additionalData)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !chunkMeta.Uncompressed {
+				stored, err = decompress(stored, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !bytes.Equal(stored, test.chunk) {
+				t.Fatal("chunk didn't round trip")
+			}
+		})
+	}
+}
+
+func TestCompressionLevels(t *testing.T) {
+	text := textChunk()
+	sizes := map[CompressionLevel]int{}
+	for _, level := range []CompressionLevel{CompressionFastest, CompressionDefault, CompressionBest} {
+		compressed, uncompressed, err := compressChunk(text, level, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if uncompressed {
+			t.Fatalf("text wasn't compressed at level %d", level)
+		}
+		sizes[level] = len(compressed)
+		putCompressBuffer(compressed)
+	}
+	if !(sizes[CompressionBest] < sizes[CompressionDefault] && sizes[CompressionDefault] < sizes[CompressionFastest]) {
+		t.Fatalf("compressed to %d bytes at the fastest level, %d by default and %d at the best", sizes[CompressionFastest], sizes[CompressionDefault], sizes[CompressionBest])
+	}
+}
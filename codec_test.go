@@ -0,0 +1,208 @@
+package bfsp_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/BillysBigFileServer/bfsp-go"
+	"github.com/BillysBigFileServer/bfsp-go/bfsptest"
+	"github.com/google/uuid"
+	"lukechampine.com/blake3"
+)
+
+// uploadTestFile uploads size random bytes as a file called name
+func uploadTestFile(t *testing.T, ctx context.Context, name string, size int, opts ...bfsp.UploadOption) (*bfsp.FileMetadata, []byte) {
+	t.Helper()
+	data := randomBytes(t, size)
+	res, err := bfsp.UploadFile(ctx, &bfsp.FileInfo{Name: name, Reader: bytes.NewReader(data)}, 4, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res.FileMetadata, data
+}
+
+// chunkArgs returns the arguments to download fileMeta's chunk at indice
+func chunkArgs(fileMeta *bfsp.FileMetadata, indice uint64) bfsp.DownloadChunkArgs {
+	return bfsp.DownloadChunkArgs{
+		ChunkID:          fileMeta.Chunks[indice],
+		FileID:           fileMeta.Id,
+		Indice:           indice,
+		Size:             fileMeta.ChunkSizes[indice],
+		ContentAddressed: fileMeta.ContentAddressed,
+		WrappedDataKey:   fileMeta.WrappedDataKey,
+	}
+}
+
+// replaceChunkData has the server send srcChunkID's ciphertext in place of dstChunkID's, keeping dstChunkID's metadata
+func replaceChunkData(t *testing.T, srv *bfsptest.Server, dstChunkID string, srcChunkID string) {
+	t.Helper()
+	srcChunk, err := srv.Storage.GetChunk(bfsptest.UserID, srcChunkID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dstChunk, err := srv.Storage.GetChunk(bfsptest.UserID, dstChunkID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dstChunk.Chunk = bytes.Clone(srcChunk.Chunk)
+	err = srv.Storage.PutChunk(bfsptest.UserID, dstChunkID, dstChunk)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDataKeys(t *testing.T) {
+	ctx, srv, masterKey := newTestContext(t)
+	fileA, _ := uploadTestFile(t, ctx, "a", 100)
+	fileB, _ := uploadTestFile(t, ctx, "b", 100)
+
+	if len(fileA.WrappedDataKey) == 0 || bytes.Equal(fileA.WrappedDataKey, fileB.WrappedDataKey) {
+		t.Fatal("files don't have their own data keys")
+	}
+
+	args := chunkArgs(fileA, 0)
+	_, err := bfsp.DownloadChunk(srv.Client(), args, masterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	args.WrappedDataKey = fileB.WrappedDataKey
+	_, err = bfsp.DownloadChunk(srv.Client(), args, masterKey)
+	if err == nil {
+		t.Fatal("decrypted a chunk with another file's data key")
+	}
+
+	// a chunk from another file is encrypted with a different key
+	replaceChunkData(t, srv, fileA.Chunks[0], fileB.Chunks[0])
+	err = bfsp.DownloadFile(ctx, fileA, &bytes.Buffer{}, "")
+	if err == nil {
+		t.Fatal("downloaded a file with another file's chunk in it")
+	}
+}
+
+// the functions that take a file id instead of its metadata encrypt like they did before files had data keys
+func TestChunkWithoutDataKey(t *testing.T) {
+	_, srv, masterKey := newTestContext(t)
+	fileID := uuid.NewString()
+	data := randomBytes(t, 100)
+	chunkHash := blake3.Sum256(data)
+	chunkMeta := &bfsp.ChunkMetadata{
+		Id:    uuid.NewString(),
+		Hash:  chunkHash[:],
+		Size:  uint32(len(data)),
+		Nonce: randomBytes(t, 24),
+	}
+
+	encChunk, err := bfsp.CompressEncryptChunk(data, chunkMeta, fileID, masterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = bfsp.UploadChunk(srv.Client(), chunkMeta, fileID, *encChunk, masterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args := bfsp.DownloadChunkArgs{ChunkID: chunkMeta.Id, FileID: fileID, Size: chunkMeta.Size}
+	downloaded, err := bfsp.DownloadChunk(srv.Client(), args, masterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(downloaded, data) {
+		t.Fatal("downloaded chunk doesn't match what was uploaded")
+	}
+}
+
+func TestChunkBoundToFile(t *testing.T) {
+	ctx, srv, masterKey := newTestContext(t)
+	fileMeta, _ := uploadTestFile(t, ctx, "file", 2*bfsp.ChunkSize)
+
+	tests := map[string]func(args *bfsp.DownloadChunkArgs){
+		"indice": func(args *bfsp.DownloadChunkArgs) { args.Indice = 1 },
+		"size":   func(args *bfsp.DownloadChunkArgs) { args.Size-- },
+	}
+	for name, modify := range tests {
+		t.Run(name, func(t *testing.T) {
+			args := chunkArgs(fileMeta, 0)
+			modify(&args)
+			_, err := bfsp.DownloadChunk(srv.Client(), args, masterKey)
+			if err == nil {
+				t.Fatal("decrypted a chunk somewhere it wasn't uploaded to")
+			}
+		})
+	}
+}
+
+func TestContentAddressedChunkNotBound(t *testing.T) {
+	ctx, srv, masterKey := newTestContext(t)
+	fileMeta, data := uploadTestFile(t, ctx, "file", 100, bfsp.WithContentDefinedChunking())
+
+	// content addressed chunks are shared between files and positions, so they're still in the unbound format
+	args := chunkArgs(fileMeta, 0)
+	args.Indice = 7
+	chunk, err := bfsp.DownloadChunk(srv.Client(), args, masterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(chunk, data) {
+		t.Fatal("downloaded chunk doesn't match what was uploaded")
+	}
+}
+
+// storedSize is how many bytes the server stores for fileMeta's chunks
+func storedSize(t *testing.T, srv *bfsptest.Server, fileMeta *bfsp.FileMetadata) int {
+	t.Helper()
+	size := 0
+	for _, chunkID := range fileMeta.Chunks {
+		chunk, err := srv.Storage.GetChunk(bfsptest.UserID, chunkID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		size += len(chunk.Chunk)
+	}
+	return size
+}
+
+func TestUploadCompression(t *testing.T) {
+	ctx, srv, _ := newTestContext(t)
+	text := bytes.Repeat([]byte("compressible text "), 2*bfsp.ChunkSize/18)
+	random := randomBytes(t, 2*bfsp.ChunkSize)
+
+	tests := map[string]struct {
+		data       []byte
+		opts       []bfsp.UploadOption
+		compressed bool
+	}{
+		"default":          {text, nil, true},
+		"best":             {text, []bfsp.UploadOption{bfsp.WithCompressionLevel(bfsp.CompressionBest)}, true},
+		"compression none": {text, []bfsp.UploadOption{bfsp.WithCompressionLevel(bfsp.CompressionNone)}, false},
+		"incompressible":   {random, nil, false},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			res, err := bfsp.UploadFile(ctx, &bfsp.FileInfo{Name: name, Reader: bytes.NewReader(test.data)}, 4, test.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fileMeta := res.FileMetadata
+
+			// an uncompressed chunk is stored as is, plus the AEAD's tag
+			size := storedSize(t, srv, fileMeta)
+			uncompressedSize := len(test.data) + 16*len(fileMeta.Chunks)
+			if test.compressed && size >= len(test.data)/10 {
+				t.Fatalf("chunks are stored in %d bytes, %d raw", size, len(test.data))
+			}
+			if !test.compressed && size != uncompressedSize {
+				t.Fatalf("chunks are stored in %d bytes, expected %d uncompressed", size, uncompressedSize)
+			}
+
+			var downloaded bytes.Buffer
+			err = bfsp.DownloadFile(ctx, fileMeta, &downloaded, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(downloaded.Bytes(), test.data) {
+				t.Fatal("downloaded file doesn't match what was uploaded")
+			}
+		})
+	}
+}
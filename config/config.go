@@ -13,6 +13,8 @@ import (
 type Config struct {
 	Token         string `json:"token"`
 	EncryptionKey string `json:"encryption_key"`
+	// KeyDerivation is the bfsp.EncodeKeyDerivation encoded derivation the master key was created with, empty for keys created with bfsp.LegacyKeyDerivation
+	KeyDerivation string `json:"key_derivation,omitempty"`
 }
 
 func (c *Config) EncryptionKeyBytes() ([]byte, error) {
@@ -0,0 +1,69 @@
+package bfsp
+
+import (
+	"bytes"
+	"context"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// FileServerHandler handles a decoded FileServerMessage and returns the response the server would send back
+type FileServerHandler interface {
+	HandleFileServerMessage(msg *FileServerMessage) (proto.Message, error)
+}
+
+// directClient sends messages straight to a FileServerHandler in the same process, going through the same encoding as the HTTP client
+type directClient struct {
+	token   string
+	handler FileServerHandler
+	ctx     context.Context
+}
+
+func NewDirectFileServerClient(token string, handler FileServerHandler) (FileServerClient, error) {
+	return &directClient{
+		token:   token,
+		handler: handler,
+		ctx:     context.Background(),
+	}, nil
+}
+
+func (cli *directClient) setToken(token string) FileServerClient {
+	newCli := *cli
+	newCli.token = token
+	return &newCli
+}
+
+func (cli *directClient) withContext(ctx context.Context) FileServerClient {
+	newCli := *cli
+	newCli.ctx = ctx
+	return &newCli
+}
+
+func (cli *directClient) SendFileServerMessage(msg isFileServerMessage_Message, resp proto.Message) error {
+	// the handler can't be interrupted, so the best we can do is not start
+	if err := cli.ctx.Err(); err != nil {
+		return err
+	}
+
+	msgBin, err := encodeFileServerMessage(msg, cli.token)
+	if err != nil {
+		return err
+	}
+
+	fullMsg, err := ReadFileServerMessage(bytes.NewReader(msgBin))
+	if err != nil {
+		return err
+	}
+
+	handlerResp, err := cli.handler.HandleFileServerMessage(fullMsg)
+	if err != nil {
+		return err
+	}
+
+	// round trip the response so callers never share memory with the handler
+	respBin, err := proto.Marshal(handlerResp)
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(respBin, resp)
+}
@@ -0,0 +1,228 @@
+package bfsp_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/BillysBigFileServer/bfsp-go"
+	"github.com/BillysBigFileServer/bfsp-go/bfsptest"
+	"google.golang.org/protobuf/proto"
+)
+
+// chunkServer is a bfsptest server that can hold up or fail downloads of particular chunks, and counts chunk uploads and downloads
+type chunkServer struct {
+	*bfsptest.Server
+	// beforeDownload is called before each chunk download is handled. If it returns an error, the download fails with it
+	beforeDownload func(chunkID string) error
+
+	uploads     atomic.Int64
+	downloads   atomic.Int64
+	inFlight    atomic.Int64
+	maxInFlight atomic.Int64
+}
+
+// newChunkServerContext returns a context with a client for a new chunkServer and a random master key
+func newChunkServerContext(t *testing.T) (context.Context, *chunkServer, bfsp.MasterKey) {
+	t.Helper()
+	_, srv, masterKey := newTestContext(t)
+	chunkSrv := &chunkServer{Server: srv}
+	cli, err := bfsp.NewDirectFileServerClient(srv.Token(), chunkSrv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := bfsp.ContextWithMasterKey(bfsp.ContextWithClient(context.Background(), cli), masterKey)
+	return ctx, chunkSrv, masterKey
+}
+
+func (s *chunkServer) HandleFileServerMessage(msg *bfsp.FileServerMessage) (proto.Message, error) {
+	if _, ok := msg.Message.(*bfsp.FileServerMessage_UploadChunk_); ok {
+		s.uploads.Add(1)
+	}
+	query, ok := msg.Message.(*bfsp.FileServerMessage_DownloadChunkQuery_)
+	if !ok || s.beforeDownload == nil {
+		return s.Server.HandleFileServerMessage(msg)
+	}
+
+	s.downloads.Add(1)
+	inFlight := s.inFlight.Add(1)
+	defer s.inFlight.Add(-1)
+	for maxInFlight := s.maxInFlight.Load(); inFlight > maxInFlight && !s.maxInFlight.CompareAndSwap(maxInFlight, inFlight); {
+		maxInFlight = s.maxInFlight.Load()
+	}
+
+	err := s.beforeDownload(query.DownloadChunkQuery.ChunkId)
+	if err != nil {
+		errStr := err.Error()
+		return &bfsp.DownloadChunkResp{Response: &bfsp.DownloadChunkResp_Err{Err: errStr}}, nil
+	}
+	return s.Server.HandleFileServerMessage(msg)
+}
+
+// uploadChunkServerFile uploads a file of chunkCount chunks, each filled with its indice so any two can be told apart
+func uploadChunkServerFile(t *testing.T, chunkCount int) (context.Context, *chunkServer, *bfsp.FileMetadata, []byte) {
+	t.Helper()
+	ctx, srv, _ := newChunkServerContext(t)
+	data := make([]byte, chunkCount*bfsp.ChunkSize)
+	for i := range data {
+		data[i] = byte(i / bfsp.ChunkSize)
+	}
+	res, err := bfsp.UploadFile(ctx, &bfsp.FileInfo{Name: "file", Reader: bytes.NewReader(data)}, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ctx, srv, res.FileMetadata, data
+}
+
+// chunkIndices maps each chunk id of fileMeta to its indice
+func chunkIndices(fileMeta *bfsp.FileMetadata) map[string]uint64 {
+	indices := map[string]uint64{}
+	for indice, chunkID := range fileMeta.Chunks {
+		indices[chunkID] = indice
+	}
+	return indices
+}
+
+func TestDownloadFileOrder(t *testing.T) {
+	ctx, srv, fileMeta, data := uploadChunkServerFile(t, 8)
+	indices := chunkIndices(fileMeta)
+	// later chunks finish first
+	srv.beforeDownload = func(chunkID string) error {
+		time.Sleep(time.Duration(len(indices)-int(indices[chunkID])) * 5 * time.Millisecond)
+		return nil
+	}
+
+	var downloaded bytes.Buffer
+	err := bfsp.DownloadFile(ctx, fileMeta, &downloaded, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(downloaded.Bytes(), data) {
+		t.Fatal("downloaded file doesn't match what was uploaded")
+	}
+	if srv.maxInFlight.Load() < 2 {
+		t.Fatal("chunks weren't downloaded concurrently")
+	}
+}
+
+func TestDownloadFileWindow(t *testing.T) {
+	ctx, srv, fileMeta, data := uploadChunkServerFile(t, 2*bfsp.DownloadWindow)
+	srv.beforeDownload = func(chunkID string) error {
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	}
+
+	var downloaded bytes.Buffer
+	err := bfsp.DownloadFile(ctx, fileMeta, &downloaded, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(downloaded.Bytes(), data) {
+		t.Fatal("downloaded file doesn't match what was uploaded")
+	}
+	if maxInFlight := srv.maxInFlight.Load(); maxInFlight > bfsp.DownloadWindow || maxInFlight < 2 {
+		t.Fatalf("%d chunks were downloaded at once, expected at most %d", maxInFlight, bfsp.DownloadWindow)
+	}
+}
+
+func TestDownloadFileFailureCancels(t *testing.T) {
+	ctx, srv, fileMeta, _ := uploadChunkServerFile(t, 2*bfsp.DownloadWindow)
+	indices := chunkIndices(fileMeta)
+	errChunk := errors.New("chunk is gone")
+	srv.beforeDownload = func(chunkID string) error {
+		if indices[chunkID] == 2 {
+			return errChunk
+		}
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	}
+	goroutines := runtime.NumGoroutine()
+
+	err := bfsp.DownloadFile(ctx, fileMeta, &bytes.Buffer{}, "")
+	if err == nil || err.Error() != errChunk.Error() {
+		t.Fatalf("got error %v, expected %v", err, errChunk)
+	}
+	// the chunks already asked for finish, but no more are started once one fails
+	if downloads := srv.downloads.Load(); downloads >= int64(len(fileMeta.Chunks)) {
+		t.Fatalf("all %d chunks were downloaded after one failed", downloads)
+	}
+	if inFlight := srv.inFlight.Load(); inFlight != 0 {
+		t.Fatalf("%d chunk downloads are still going after DownloadFile returned", inFlight)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > goroutines && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if leaked := runtime.NumGoroutine() - goroutines; leaked > 0 {
+		t.Fatalf("%d goroutines leaked", leaked)
+	}
+}
+
+func TestOpenFile(t *testing.T) {
+	ctx, _, fileMeta, data := uploadChunkServerFile(t, 8)
+	r, err := bfsp.OpenFile(ctx, fileMeta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	downloaded, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(downloaded, data) {
+		t.Fatal("read file doesn't match what was uploaded")
+	}
+	err = r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenFileClose(t *testing.T) {
+	ctx, srv, fileMeta, data := uploadChunkServerFile(t, 2*bfsp.DownloadWindow)
+	srv.beforeDownload = func(chunkID string) error {
+		time.Sleep(20 * time.Millisecond)
+		return nil
+	}
+	goroutines := runtime.NumGoroutine()
+
+	r, err := bfsp.OpenFile(ctx, fileMeta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := make([]byte, 10)
+	_, err = io.ReadFull(r, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(p, data[:10]) {
+		t.Fatal("read the wrong data")
+	}
+
+	// closing before reading everything stops the download, and waits for it to stop
+	err = r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if downloads := srv.downloads.Load(); downloads >= int64(len(fileMeta.Chunks)) {
+		t.Fatalf("all %d chunks were downloaded after the file was closed", downloads)
+	}
+	if inFlight := srv.inFlight.Load(); inFlight != 0 {
+		t.Fatalf("%d chunk downloads are still going after Close returned", inFlight)
+	}
+	waitFor(t, func() bool { return runtime.NumGoroutine() <= goroutines })
+
+	n, err := r.Read(p)
+	if n != 0 || !errors.Is(err, fs.ErrClosed) {
+		t.Fatalf("Read after Close returned %d, %v, expected 0, fs.ErrClosed", n, err)
+	}
+	if err := r.Close(); !errors.Is(err, fs.ErrClosed) {
+		t.Fatalf("got error %v closing twice, expected fs.ErrClosed", err)
+	}
+}
@@ -2,11 +2,13 @@ package bfsp
 
 import (
 	"context"
-	"encoding/base64"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
 
 	"github.com/google/uuid"
-	"github.com/klauspost/compress/zstd"
-	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/chacha20poly1305"
 	"google.golang.org/protobuf/proto"
 	"lukechampine.com/blake3"
@@ -16,69 +18,282 @@ const SaltString = "g8QqYqhXxwJj037KswzK3g"
 
 type MasterKey []byte
 
+// CreateMasterEncKey derives a master key with LegacyKeyDerivation. New users should get their own derivation with SetUpMasterKey
 func CreateMasterEncKey(password string) (MasterKey, error) {
-	salt, err := base64.RawStdEncoding.DecodeString(SaltString)
+	return DeriveMasterKey(password, LegacyKeyDerivation())
+}
+
+var ErrIncorrectPassword = errors.New("incorrect password")
+
+const escrowAdditionalData = "bfsp escrowed master key"
+
+// escrowMasterKey encrypts masterKey with password, using a fresh salt and nonce every time
+func escrowMasterKey(masterKey MasterKey, password string) (*EscrowedMasterKey, error) {
+	kdf, err := NewKeyDerivation()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, chacha20poly1305.NonceSizeX)
+	_, err = rand.Read(nonce)
 	if err != nil {
 		return nil, err
 	}
 
-	default_m_cost := 19 * 1024
-	default_t_cost := 2
-	default_p_cost := 1
-	default_output_size := 32
-	// TOOD(billy): can we ever just switch to correctly setting the output size instead of this double hash bullshit
-	passwordHashArgon := argon2.IDKey([]byte(password), []byte(salt), uint32(default_t_cost), uint32(default_m_cost), uint8(default_p_cost), uint32(default_output_size))
-	masterKey := blake3.Sum256(passwordHashArgon)
+	escrowKey, err := deriveKey(password, kdf)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := chacha20poly1305.NewX(escrowKey)
+	if err != nil {
+		return nil, err
+	}
 
-	return masterKey[:], nil
+	return &EscrowedMasterKey{
+		Kdf:          kdf,
+		Nonce:        nonce,
+		EncryptedKey: enc.Seal(nil, nonce, masterKey, []byte(escrowAdditionalData)),
+	}, nil
 }
 
-func CompressEncryptChunk(chunkBytes []byte, chunkMetadata *ChunkMetadata, fileId string, masterKey MasterKey) (*EncryptedCompressedChunk, error) {
-	zstdEncoder, err := zstd.NewWriter(nil)
+func unescrowMasterKey(escrowedKey *EscrowedMasterKey, password string) (MasterKey, error) {
+	if escrowedKey.Kdf == nil {
+		return nil, errors.New("escrowed master key has no key derivation")
+	}
+	escrowKey, err := deriveKey(password, escrowedKey.Kdf)
 	if err != nil {
 		return nil, err
 	}
-	defer zstdEncoder.Close()
+	enc, err := chacha20poly1305.NewX(escrowKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(escrowedKey.Nonce) != enc.NonceSize() {
+		return nil, fmt.Errorf("escrowed master key nonce is %d bytes, expected %d", len(escrowedKey.Nonce), enc.NonceSize())
+	}
+
+	masterKey, err := enc.Open(nil, escrowedKey.Nonce, escrowedKey.EncryptedKey, []byte(escrowAdditionalData))
+	if err != nil {
+		return nil, ErrIncorrectPassword
+	}
+	return masterKey, nil
+}
 
-	compressedChunkBytes := zstdEncoder.EncodeAll(chunkBytes, nil)
+// MasterKeyFingerprint identifies masterKey without giving it away, so copies of a key can be checked against each other
+func MasterKeyFingerprint(masterKey MasterKey) []byte {
+	fingerprint := make([]byte, 16)
+	blake3.DeriveKey(fingerprint, "bfsp master key id", masterKey)
+	return fingerprint
+}
+
+// deriveFileKey derives the key a file's metadata and chunks are encrypted with
+func deriveFileKey(masterKey MasterKey, fileId string) ([]byte, error) {
+	fileUUID, err := uuid.Parse(fileId)
+	if err != nil {
+		return nil, err
+	}
 
-	fileUUID := uuid.MustParse(fileId)
-	fileUUIDBin, err := fileUUID.MarshalBinary()
-	fileKeyBytes := masterKey[:]
-	fileKeyBytes = append(fileKeyBytes, fileUUIDBin...)
+	fileKeyBytes := append([]byte{}, masterKey...)
+	fileKeyBytes = append(fileKeyBytes, fileUUID[:]...)
 	fileKey := blake3.Sum256(fileKeyBytes)
 
-	enc, err := chacha20poly1305.NewX(fileKey[:])
+	return fileKey[:], nil
+}
+
+// newDataKey creates a random key for a new file, and wraps it with masterKey so it can be stored in FileMetadata.WrappedDataKey
+func newDataKey(fileId string, masterKey MasterKey) ([]byte, []byte, error) {
+	dataKey := make([]byte, chacha20poly1305.KeySize)
+	_, err := rand.Read(dataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	wrappedDataKey, err := wrapDataKey(dataKey, fileId, masterKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return dataKey, wrappedDataKey, nil
+}
+
+func deriveKeyWrappingKey(masterKey MasterKey) []byte {
+	key := make([]byte, chacha20poly1305.KeySize)
+	blake3.DeriveKey(key, "bfsp data key wrapping key", masterKey)
+	return key
+}
+
+// wrapDataKey encrypts a file's data key with masterKey. The file id is authenticated too, so a wrapped key can't be moved to another file
+func wrapDataKey(dataKey []byte, fileId string, masterKey MasterKey) ([]byte, error) {
+	fileUUID, err := uuid.Parse(fileId)
 	if err != nil {
 		return nil, err
 	}
-	encryptedChunkBytes := enc.Seal(nil, chunkMetadata.Nonce, compressedChunkBytes, []byte(chunkMetadata.Id))
+
+	enc, err := chacha20poly1305.NewX(deriveKeyWrappingKey(masterKey))
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, enc.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return enc.Seal(nonce, nonce, dataKey, fileUUID[:]), nil
+}
+
+func unwrapDataKey(wrappedDataKey []byte, fileId string, masterKey MasterKey) ([]byte, error) {
+	fileUUID, err := uuid.Parse(fileId)
+	if err != nil {
+		return nil, err
+	}
+
+	enc, err := chacha20poly1305.NewX(deriveKeyWrappingKey(masterKey))
+	if err != nil {
+		return nil, err
+	}
+	if len(wrappedDataKey) < enc.NonceSize() {
+		return nil, fmt.Errorf("wrapped data key is too short")
+	}
+
+	nonce := wrappedDataKey[:enc.NonceSize()]
+	dataKey, err := enc.Open(nil, nonce, wrappedDataKey[enc.NonceSize():], fileUUID[:])
+	if err != nil {
+		return nil, fmt.Errorf("error unwrapping data key of file %s: %w", fileId, err)
+	}
+	return dataKey, nil
+}
+
+// fileEncKey returns the key a file's metadata and chunks are encrypted with: its data key if it has one, otherwise the key derived from the master key
+func fileEncKey(fileId string, wrappedDataKey []byte, masterKey MasterKey) ([]byte, error) {
+	if len(wrappedDataKey) > 0 {
+		return unwrapDataKey(wrappedDataKey, fileId, masterKey)
+	}
+	return deriveFileKey(masterKey, fileId)
+}
+
+// deriveContentAddressedKey derives the key content addressed chunks are encrypted with. It's the same for every file, otherwise identical chunks couldn't be shared
+func deriveContentAddressedKey(masterKey MasterKey) []byte {
+	key := make([]byte, 32)
+	blake3.DeriveKey(key, "bfsp content addressed chunk encryption key", masterKey)
+	return key
+}
+
+// contentAddressedChunkID names a chunk by a hash of its contents, keyed so the server can't confirm guesses about what a user has stored
+func contentAddressedChunkID(masterKey MasterKey, chunkBytes []byte) string {
+	idKey := make([]byte, 32)
+	blake3.DeriveKey(idKey, "bfsp content addressed chunk id", masterKey)
+
+	hasher := blake3.New(16, idKey)
+	hasher.Write(chunkBytes)
+	chunkID := uuid.UUID(hasher.Sum(nil))
+	// mark it as a v8 (custom) uuid
+	chunkID[6] = (chunkID[6] & 0x0f) | 0x80
+	chunkID[8] = (chunkID[8] & 0x3f) | 0x80
+
+	return chunkID.String()
+}
+
+// CompressEncryptChunk encrypts a chunk of a file without a data key, with the key derived from masterKey and fileId.
+// files uploaded with UploadFile have a data key, use CompressEncryptChunkWithDataKey for them
+func CompressEncryptChunk(chunkBytes []byte, chunkMetadata *ChunkMetadata, fileId string, masterKey MasterKey) (*EncryptedCompressedChunk, error) {
+	return CompressEncryptChunkWithDataKey(chunkBytes, chunkMetadata, &FileMetadata{Id: fileId}, masterKey)
+}
+
+// CompressEncryptChunkWithDataKey encrypts a chunk of fileMeta with the file's data key, or the key derived from masterKey if it doesn't have one
+func CompressEncryptChunkWithDataKey(chunkBytes []byte, chunkMetadata *ChunkMetadata, fileMeta *FileMetadata, masterKey MasterKey) (*EncryptedCompressedChunk, error) {
+	chunkAEAD, err := newChunkCodec(masterKey).chunkAEAD(fileMeta)
+	if err != nil {
+		return nil, err
+	}
+
+	return compressEncryptChunk(chunkBytes, chunkMetadata, fileMeta.Id, chunkAEAD, CompressionDefault, fileMeta.Padded)
+}
+
+// ChunkMetadata.FormatVersion
+const (
+	// the chunk id is the only associated data
+	chunkFormatLegacy = 0
+	// the file id, chunk id, indice and size are all associated data, so a chunk can't be passed off as part of another file or at another position.
+	// content addressed chunks can be part of any file at any position, so they always use chunkFormatLegacy. Their id is a hash of their contents anyways
+	chunkFormatBound = 1
+)
+
+// chunkAdditionalData returns the associated data a chunk's contents are encrypted with
+func chunkAdditionalData(formatVersion uint32, fileId string, chunkId string, indice uint64, size uint32) ([]byte, error) {
+	switch formatVersion {
+	case chunkFormatLegacy:
+		return []byte(chunkId), nil
+	case chunkFormatBound:
+		fileUUID, err := uuid.Parse(fileId)
+		if err != nil {
+			return nil, err
+		}
+		chunkUUID, err := uuid.Parse(chunkId)
+		if err != nil {
+			return nil, err
+		}
+
+		additionalData := []byte("bfsp chunk v1")
+		additionalData = append(additionalData, fileUUID[:]...)
+		additionalData = append(additionalData, chunkUUID[:]...)
+		additionalData = binary.LittleEndian.AppendUint64(additionalData, indice)
+		additionalData = binary.LittleEndian.AppendUint32(additionalData, size)
+		return additionalData, nil
+	default:
+		return nil, fmt.Errorf("unsupported chunk format version %d", formatVersion)
+	}
+}
+
+// compressEncryptChunk sets chunkMetadata.Uncompressed if the chunk is stored uncompressed, so the chunk metadata has to be encrypted after it
+func compressEncryptChunk(chunkBytes []byte, chunkMetadata *ChunkMetadata, fileId string, aead cipher.AEAD, level CompressionLevel, pad bool) (*EncryptedCompressedChunk, error) {
+	additionalData, err := chunkAdditionalData(chunkMetadata.FormatVersion, fileId, chunkMetadata.Id, uint64(chunkMetadata.Indice), chunkMetadata.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	compressedChunkBytes, uncompressed, err := compressChunk(chunkBytes, level, pad)
+	if err != nil {
+		return nil, err
+	}
+	defer putCompressBuffer(compressedChunkBytes)
+	chunkMetadata.Uncompressed = uncompressed
+
+	encryptedChunkBytes := make([]byte, 0, len(compressedChunkBytes)+aead.Overhead())
+	encryptedChunkBytes = aead.Seal(encryptedChunkBytes, chunkMetadata.Nonce, compressedChunkBytes, additionalData)
 
 	return &EncryptedCompressedChunk{
 		chunk: encryptedChunkBytes,
 	}, nil
 }
 
+// CompressEncryptChunkMetadata encrypts the metadata of a chunk of a file without a data key, like CompressEncryptChunk
 func CompressEncryptChunkMetadata(chunkMetadata *ChunkMetadata, fileId string, masterKey MasterKey) ([]byte, error) {
-	zstdEncoder, err := zstd.NewWriter(nil)
+	return CompressEncryptChunkMetadataWithDataKey(chunkMetadata, &FileMetadata{Id: fileId}, masterKey)
+}
+
+// CompressEncryptChunkMetadataWithDataKey encrypts the metadata of a chunk of fileMeta, like CompressEncryptChunkWithDataKey
+func CompressEncryptChunkMetadataWithDataKey(chunkMetadata *ChunkMetadata, fileMeta *FileMetadata, masterKey MasterKey) ([]byte, error) {
+	chunkAEAD, err := newChunkCodec(masterKey).chunkAEAD(fileMeta)
 	if err != nil {
 		return nil, err
 	}
-	defer zstdEncoder.Close()
-
-	b, err := proto.Marshal(chunkMetadata)
-	compressedChunkBytes := zstdEncoder.EncodeAll(b, nil)
 
-	fileUUID := uuid.MustParse(fileId)
-	fileUUIDBin, err := fileUUID.MarshalBinary()
-	fileKeyBytes := masterKey[:]
-	fileKeyBytes = append(fileKeyBytes, fileUUIDBin...)
-	fileKey := blake3.Sum256(fileKeyBytes)
+	if fileMeta.ContentAddressed {
+		return compressEncryptContentAddressedChunkMetadata(chunkMetadata, chunkAEAD, fileMeta.Padded)
+	}
+	return compressEncryptChunkMetadata(chunkMetadata, chunkAEAD, fileMeta.Padded)
+}
 
-	enc, err := chacha20poly1305.NewX(fileKey[:])
+func compressEncryptChunkMetadata(chunkMetadata *ChunkMetadata, aead cipher.AEAD, pad bool) ([]byte, error) {
+	b, err := proto.Marshal(chunkMetadata)
+	if err != nil {
+		return nil, err
+	}
+	compressedChunkBytes, err := compress(b, CompressionDefault, pad)
 	if err != nil {
 		return nil, err
 	}
+	defer putCompressBuffer(compressedChunkBytes)
+
 	chunkMetaUUID, err := uuid.Parse(chunkMetadata.Id)
 	if err != nil {
 		return nil, err
@@ -89,10 +304,36 @@ func CompressEncryptChunkMetadata(chunkMetadata *ChunkMetadata, fileId string, m
 	}
 	nonce = append(nonce, make([]byte, 24-len(nonce))...)
 
-	encryptedChunkMetaBytes := enc.Seal(nil, nonce, compressedChunkBytes, chunkMetaUUID[:])
+	encryptedChunkMetaBytes := aead.Seal(nil, nonce, compressedChunkBytes, chunkMetaUUID[:])
 	return encryptedChunkMetaBytes, nil
 }
 
+// content addressed chunks can be uploaded more than once under the same key, so unlike other chunks their metadata can't use the chunk id as a nonce.
+// a random nonce is prepended instead
+func compressEncryptContentAddressedChunkMetadata(chunkMetadata *ChunkMetadata, aead cipher.AEAD, pad bool) ([]byte, error) {
+	b, err := proto.Marshal(chunkMetadata)
+	if err != nil {
+		return nil, err
+	}
+	compressedChunkBytes, err := compress(b, CompressionDefault, pad)
+	if err != nil {
+		return nil, err
+	}
+	defer putCompressBuffer(compressedChunkBytes)
+
+	chunkMetaUUID, err := uuid.Parse(chunkMetadata.Id)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(compressedChunkBytes)+aead.Overhead())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, compressedChunkBytes, chunkMetaUUID[:]), nil
+}
+
 type keyContextKeyType struct{}
 
 var keyContextKey = keyContextKeyType{}
@@ -0,0 +1,8 @@
+package bfsp
+
+// exported for tests in package bfsp_test, which can't be in package bfsp since bfsptest imports it
+const (
+	DownloadWindow      = downloadWindow
+	RemoteFileCacheSize = remoteFileCacheSize
+	RemoteFileReadahead = remoteFileReadahead
+)
@@ -2,6 +2,7 @@ package bfsp
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"net/http"
 	"net/url"
@@ -14,6 +15,7 @@ type httpClient struct {
 	token   string
 	baseUrl string
 	https   bool
+	ctx     context.Context
 }
 
 func NewHTTPFileServerClient(token string, baseUrl string, https bool) (FileServerClient, error) {
@@ -21,6 +23,7 @@ func NewHTTPFileServerClient(token string, baseUrl string, https bool) (FileServ
 		token:   token,
 		baseUrl: baseUrl,
 		https:   https,
+		ctx:     context.Background(),
 	}, nil
 }
 
@@ -30,6 +33,12 @@ func (cli *httpClient) setToken(token string) FileServerClient {
 	return &newCli
 }
 
+func (cli *httpClient) withContext(ctx context.Context) FileServerClient {
+	newCli := *cli
+	newCli.ctx = ctx
+	return &newCli
+}
+
 func (cli *httpClient) SendFileServerMessage(msg isFileServerMessage_Message, resp proto.Message) error {
 	msgBin, err := encodeFileServerMessage(msg, cli.token)
 	if err != nil {
@@ -62,6 +71,7 @@ func (cli *httpClient) SendFileServerMessage(msg isFileServerMessage_Message, re
 		},
 		Body: io.NopCloser(reader),
 	}
+	req = req.WithContext(cli.ctx)
 
 	respBin, err := http.DefaultClient.Do(req)
 	if err != nil {
@@ -0,0 +1,230 @@
+package bfsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrSourceChanged = errors.New("source file changed since the upload started")
+
+const journalSuffix = ".upload-journal"
+
+// the first line of a journal file. Every line after it is a journalChunk
+type journalHeader struct {
+	FileID           string           `json:"file_id"`
+	FileName         string           `json:"file_name"`
+	SourceSize       int64            `json:"source_size"`
+	SourceModTime    int64            `json:"source_mod_time"`
+	ContentAddressed bool             `json:"content_addressed"`
+	WrappedDataKey   []byte           `json:"wrapped_data_key,omitempty"`
+	Padded           bool             `json:"padded,omitempty"`
+	CompressionLevel CompressionLevel `json:"compression_level,omitempty"`
+}
+
+type journalChunk struct {
+	Indice  uint64 `json:"indice"`
+	ChunkID string `json:"chunk_id"`
+	Nonce   []byte `json:"nonce"`
+	Hash    []byte `json:"hash"`
+	Size    uint32 `json:"size"`
+}
+
+// uploadJournal records an upload's progress on disk, one line per chunk, so that ResumeUpload can pick up where it stopped
+type uploadJournal struct {
+	header journalHeader
+	chunks map[uint64]*journalChunk
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+type PendingUpload struct {
+	FileID        string
+	FileName      string
+	SourceSize    int64
+	SourceModTime time.Time
+}
+
+func journalPath(journalDir string, fileID string) string {
+	return filepath.Join(journalDir, fileID+journalSuffix)
+}
+
+// sourceStat returns the size and modification time of the file being uploaded, if the reader knows them
+func sourceStat(fileInfo *FileInfo) (int64, int64) {
+	statReader, ok := fileInfo.Reader.(interface{ Stat() (fs.FileInfo, error) })
+	if !ok {
+		return 0, 0
+	}
+	stat, err := statReader.Stat()
+	if err != nil {
+		return 0, 0
+	}
+	return stat.Size(), stat.ModTime().UnixNano()
+}
+
+func createUploadJournal(journalDir string, fileID string, fileInfo *FileInfo, options uploadOptions, wrappedDataKey []byte) (*uploadJournal, error) {
+	err := os.MkdirAll(journalDir, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	sourceSize, sourceModTime := sourceStat(fileInfo)
+	header := journalHeader{
+		FileID:           fileID,
+		FileName:         fileInfo.Name,
+		SourceSize:       sourceSize,
+		SourceModTime:    sourceModTime,
+		ContentAddressed: options.contentDefinedChunking,
+		WrappedDataKey:   wrappedDataKey,
+		Padded:           options.padded,
+		CompressionLevel: options.compressionLevel,
+	}
+	headerJson, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(journalPath(journalDir, fileID), os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	_, err = file.Write(append(headerJson, '\n'))
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &uploadJournal{
+		header: header,
+		chunks: map[uint64]*journalChunk{},
+		file:   file,
+	}, nil
+}
+
+func readJournalFile(path string) (*journalHeader, map[uint64]*journalChunk, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	if !scanner.Scan() {
+		if scanner.Err() != nil {
+			return nil, nil, scanner.Err()
+		}
+		return nil, nil, fmt.Errorf("upload journal %s is empty", path)
+	}
+	var header journalHeader
+	err = json.Unmarshal(scanner.Bytes(), &header)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	chunks := map[uint64]*journalChunk{}
+	for scanner.Scan() {
+		var chunk journalChunk
+		err = json.Unmarshal(scanner.Bytes(), &chunk)
+		if err != nil {
+			// an upload died partway through writing this line. Forgetting it only means the chunk gets uploaded again
+			continue
+		}
+		chunks[chunk.Indice] = &chunk
+	}
+	if scanner.Err() != nil {
+		return nil, nil, scanner.Err()
+	}
+
+	return &header, chunks, nil
+}
+
+func openUploadJournal(journalDir string, fileID string) (*uploadJournal, error) {
+	path := journalPath(journalDir, fileID)
+	header, chunks, err := readJournalFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	// make sure a cut off line doesn't swallow the next one
+	_, err = file.Write([]byte{'\n'})
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &uploadJournal{
+		header: *header,
+		chunks: chunks,
+		file:   file,
+	}, nil
+}
+
+func (j *uploadJournal) record(chunk *journalChunk) error {
+	chunkJson, err := json.Marshal(chunk)
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	// a single write, so a crash can only ever cut off the last line
+	_, err = j.file.Write(append(chunkJson, '\n'))
+	return err
+}
+
+func (j *uploadJournal) close() error {
+	return j.file.Close()
+}
+
+// remove deletes the journal once the upload is finished
+func (j *uploadJournal) remove() error {
+	err := j.file.Close()
+	if err != nil && !errors.Is(err, os.ErrClosed) {
+		return err
+	}
+	return os.Remove(j.file.Name())
+}
+
+// PendingUploads lists the uploads in journalDir that haven't finished, and can be continued with ResumeUpload
+func PendingUploads(journalDir string) ([]*PendingUpload, error) {
+	entries, err := os.ReadDir(journalDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []*PendingUpload{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	pendingUploads := []*PendingUpload{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), journalSuffix) {
+			continue
+		}
+
+		header, _, err := readJournalFile(filepath.Join(journalDir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		pendingUploads = append(pendingUploads, &PendingUpload{
+			FileID:        header.FileID,
+			FileName:      header.FileName,
+			SourceSize:    header.SourceSize,
+			SourceModTime: time.Unix(0, header.SourceModTime),
+		})
+	}
+
+	return pendingUploads, nil
+}
@@ -0,0 +1,205 @@
+package bfsp_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/BillysBigFileServer/bfsp-go"
+)
+
+var errInterrupted = errors.New("source went away")
+
+// interruptedReader reads the first after bytes of r, then fails once ready returns true, like a source on a disk that went away partway through
+type interruptedReader struct {
+	r     io.Reader
+	after int
+	ready func() bool
+	read  int
+}
+
+func (r *interruptedReader) Read(p []byte) (int, error) {
+	if r.read >= r.after {
+		deadline := time.Now().Add(5 * time.Second)
+		for !r.ready() && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		return 0, errInterrupted
+	}
+	if len(p) > r.after-r.read {
+		p = p[:r.after-r.read]
+	}
+	n, err := r.r.Read(p)
+	r.read += n
+	return n, err
+}
+
+// interruptedFile is an interruptedReader of a file, which uploads can stat
+type interruptedFile struct {
+	*interruptedReader
+	file *os.File
+}
+
+func (f *interruptedFile) Stat() (fs.FileInfo, error) {
+	return f.file.Stat()
+}
+
+// interruptUpload uploads source, which has to be an interruptedReader that's ready once landedChunks chunks are on srv, with a journal in journalDir.
+// it returns the id of the upload left to resume
+func interruptUpload(t *testing.T, ctx context.Context, srv *chunkServer, journalDir string, source io.Reader, landedChunks int, opts ...bfsp.UploadOption) string {
+	t.Helper()
+	_, err := bfsp.UploadFile(ctx, &bfsp.FileInfo{Name: "file", Reader: source}, 2, append(opts, bfsp.WithUploadJournal(journalDir))...)
+	if !errors.Is(err, errInterrupted) {
+		t.Fatalf("got error %v, expected the upload to be interrupted", err)
+	}
+	if srv.ChunkCount() < landedChunks {
+		t.Fatalf("only %d chunks landed before the upload was interrupted", srv.ChunkCount())
+	}
+
+	pendingUploads, err := bfsp.PendingUploads(journalDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pendingUploads) != 1 || pendingUploads[0].FileName != "file" {
+		t.Fatalf("got pending uploads %v, expected the interrupted one", pendingUploads)
+	}
+	return pendingUploads[0].FileID
+}
+
+func TestResumeUpload(t *testing.T) {
+	tests := map[string]struct {
+		opts         []bfsp.UploadOption
+		size         int
+		landedChunks int
+	}{
+		"fixed size": {nil, 8*bfsp.ChunkSize + 123, 2},
+		// content defined chunks are checked with the server, and only then uploaded, 16 at a time
+		"content defined": {[]bfsp.UploadOption{bfsp.WithContentDefinedChunking()}, 32*bfsp.ChunkSize + 123, 16},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx, srv, _ := newChunkServerContext(t)
+			journalDir := t.TempDir()
+			data := randomBytes(t, test.size)
+
+			source := &interruptedReader{
+				r:     bytes.NewReader(data),
+				after: 3 * len(data) / 4,
+				ready: func() bool { return srv.ChunkCount() >= test.landedChunks },
+			}
+			fileID := interruptUpload(t, ctx, srv, journalDir, source, test.landedChunks, test.opts...)
+			landedChunks := srv.ChunkCount()
+			uploadsBefore := srv.uploads.Load()
+
+			res, err := bfsp.ResumeUpload(ctx, journalDir, fileID, &bfsp.FileInfo{Name: "file", Reader: bytes.NewReader(data)}, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.FileMetadata.Id != fileID {
+				t.Fatalf("resumed upload is file %s, expected %s", res.FileMetadata.Id, fileID)
+			}
+			// the chunks that landed before the upload was interrupted aren't uploaded again
+			if uploads, missingChunks := srv.uploads.Load()-uploadsBefore, int64(srv.ChunkCount()-landedChunks); uploads != missingChunks {
+				t.Fatalf("resuming uploaded %d chunks, expected the %d missing ones", uploads, missingChunks)
+			}
+			if srv.ChunkCount() != len(res.FileMetadata.Chunks) {
+				t.Fatalf("server has %d chunks, the file has %d", srv.ChunkCount(), len(res.FileMetadata.Chunks))
+			}
+
+			var downloaded bytes.Buffer
+			err = bfsp.DownloadFile(ctx, res.FileMetadata, &downloaded, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(downloaded.Bytes(), data) {
+				t.Fatal("downloaded file doesn't match what was uploaded")
+			}
+
+			pendingUploads, err := bfsp.PendingUploads(journalDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			journalFiles, err := os.ReadDir(journalDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(pendingUploads) != 0 || len(journalFiles) != 0 {
+				t.Fatalf("the journal is still there after the upload finished: %v", journalFiles)
+			}
+		})
+	}
+}
+
+func TestResumeUploadSourceChanged(t *testing.T) {
+	t.Run("contents", func(t *testing.T) {
+		ctx, srv, _ := newChunkServerContext(t)
+		journalDir := t.TempDir()
+		data := randomBytes(t, 4*bfsp.ChunkSize)
+
+		source := &interruptedReader{
+			r:     bytes.NewReader(data),
+			after: len(data) / 2,
+			ready: func() bool { return srv.ChunkCount() >= 2 },
+		}
+		fileID := interruptUpload(t, ctx, srv, journalDir, source, 2)
+
+		// the source can't be statted, so the change is only noticed when the first chunk doesn't match the journal
+		changedData := bytes.Clone(data)
+		changedData[10]++
+		_, err := bfsp.ResumeUpload(ctx, journalDir, fileID, &bfsp.FileInfo{Name: "file", Reader: bytes.NewReader(changedData)}, 2)
+		if !errors.Is(err, bfsp.ErrSourceChanged) {
+			t.Fatalf("got error %v, expected ErrSourceChanged", err)
+		}
+	})
+
+	t.Run("modification time", func(t *testing.T) {
+		ctx, srv, _ := newChunkServerContext(t)
+		journalDir := t.TempDir()
+		data := randomBytes(t, 4*bfsp.ChunkSize)
+		sourcePath := filepath.Join(t.TempDir(), "source")
+		err := os.WriteFile(sourcePath, data, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sourceFile, err := os.Open(sourcePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer sourceFile.Close()
+		source := &interruptedFile{
+			interruptedReader: &interruptedReader{
+				r:     sourceFile,
+				after: len(data) / 2,
+				ready: func() bool { return srv.ChunkCount() >= 2 },
+			},
+			file: sourceFile,
+		}
+		fileID := interruptUpload(t, ctx, srv, journalDir, source, 2)
+
+		modTime := time.Now().Add(time.Hour)
+		err = os.Chtimes(sourcePath, modTime, modTime)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resumedFile, err := os.Open(sourcePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resumedFile.Close()
+		uploadsBefore := srv.uploads.Load()
+		_, err = bfsp.ResumeUpload(ctx, journalDir, fileID, &bfsp.FileInfo{Name: "file", Reader: resumedFile}, 2)
+		if !errors.Is(err, bfsp.ErrSourceChanged) {
+			t.Fatalf("got error %v, expected ErrSourceChanged", err)
+		}
+		if srv.uploads.Load() != uploadsBefore {
+			t.Fatal("chunks were uploaded from a source that changed")
+		}
+	})
+}
@@ -0,0 +1,166 @@
+package bfsp
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/BillysBigFileServer/bfsp-go/config"
+	"golang.org/x/crypto/argon2"
+	"google.golang.org/protobuf/proto"
+	"lukechampine.com/blake3"
+)
+
+const (
+	kdfAlgorithmArgon2id = "argon2id"
+
+	// the version NewKeyDerivation creates
+	currentKDFVersion = 1
+	// RFC 9106's second recommended option, for when 2GiB of memory isn't available
+	currentKDFMCost      = 64 * 1024
+	currentKDFTCost      = 3
+	currentKDFPCost      = 4
+	currentKDFOutputSize = 32
+	currentKDFSaltSize   = 16
+
+	// the derivation comes from the server in FetchMasterKey, so these stop a malicious one from making the client hash with gigabytes of memory or for hours.
+	// 2GiB is RFC 9106's first recommended option
+	maxKDFMCost = 2 * 1024 * 1024
+	maxKDFTCost = 16
+)
+
+// LegacyKeyDerivation is the derivation CreateMasterEncKey has always used, which every existing master key was created with
+func LegacyKeyDerivation() *KeyDerivation {
+	salt, err := base64.RawStdEncoding.DecodeString(SaltString)
+	if err != nil {
+		panic(err)
+	}
+
+	return &KeyDerivation{
+		Version:    0,
+		Algorithm:  kdfAlgorithmArgon2id,
+		Salt:       salt,
+		MCost:      19 * 1024,
+		TCost:      2,
+		PCost:      1,
+		OutputSize: 32,
+	}
+}
+
+// NewKeyDerivation creates a derivation with a random salt and the current recommended parameters, for a new user
+func NewKeyDerivation() (*KeyDerivation, error) {
+	salt := make([]byte, currentKDFSaltSize)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeyDerivation{
+		Version:    currentKDFVersion,
+		Algorithm:  kdfAlgorithmArgon2id,
+		Salt:       salt,
+		MCost:      currentKDFMCost,
+		TCost:      currentKDFTCost,
+		PCost:      currentKDFPCost,
+		OutputSize: currentKDFOutputSize,
+	}, nil
+}
+
+func validateKeyDerivation(kdf *KeyDerivation) error {
+	if kdf.Version > currentKDFVersion {
+		return fmt.Errorf("unsupported key derivation version %d", kdf.Version)
+	}
+	if kdf.Algorithm != kdfAlgorithmArgon2id {
+		return fmt.Errorf("unsupported key derivation algorithm %q", kdf.Algorithm)
+	}
+	if len(kdf.Salt) < 8 {
+		return fmt.Errorf("key derivation salt is %d bytes, it must be at least 8", len(kdf.Salt))
+	}
+	if kdf.TCost < 1 || kdf.PCost < 1 || kdf.PCost > 255 || kdf.MCost < 8*kdf.PCost {
+		return fmt.Errorf("invalid argon2id costs m=%d t=%d p=%d", kdf.MCost, kdf.TCost, kdf.PCost)
+	}
+	if kdf.MCost > maxKDFMCost || kdf.TCost > maxKDFTCost {
+		return fmt.Errorf("argon2id costs m=%d t=%d are over the limits of m=%d t=%d", kdf.MCost, kdf.TCost, maxKDFMCost, maxKDFTCost)
+	}
+	if kdf.OutputSize < 32 || kdf.OutputSize > 1024 {
+		return fmt.Errorf("key derivation output size %d must be between 32 and 1024", kdf.OutputSize)
+	}
+	return nil
+}
+
+// DeriveMasterKey derives a user's master key from their password with kdf
+func DeriveMasterKey(password string, kdf *KeyDerivation) (MasterKey, error) {
+	key, err := deriveKey(password, kdf)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// SetUpMasterKey derives a new user's master key from password with a derivation from NewKeyDerivation, and saves the derivation in cfg so the key can be derived again
+func SetUpMasterKey(cfg *config.Config, password string) (MasterKey, error) {
+	kdf, err := NewKeyDerivation()
+	if err != nil {
+		return nil, err
+	}
+	masterKey, err := DeriveMasterKey(password, kdf)
+	if err != nil {
+		return nil, err
+	}
+	encodedKDF, err := EncodeKeyDerivation(kdf)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.KeyDerivation = encodedKDF
+	return masterKey, nil
+}
+
+// ConfigKeyDerivation returns the derivation saved in cfg by SetUpMasterKey, or LegacyKeyDerivation for accounts created before derivations were saved
+func ConfigKeyDerivation(cfg *config.Config) (*KeyDerivation, error) {
+	if cfg.KeyDerivation == "" {
+		return LegacyKeyDerivation(), nil
+	}
+	return DecodeKeyDerivation(cfg.KeyDerivation)
+}
+
+func deriveKey(password string, kdf *KeyDerivation) ([]byte, error) {
+	err := validateKeyDerivation(kdf)
+	if err != nil {
+		return nil, err
+	}
+
+	key := argon2.IDKey([]byte(password), kdf.Salt, kdf.TCost, kdf.MCost, uint8(kdf.PCost), kdf.OutputSize)
+	if kdf.Version == 0 {
+		hashedKey := blake3.Sum256(key)
+		return hashedKey[:], nil
+	}
+	return key, nil
+}
+
+// EncodeKeyDerivation serializes kdf so it can be stored alongside the user's account
+func EncodeKeyDerivation(kdf *KeyDerivation) (string, error) {
+	bin, err := proto.Marshal(kdf)
+	if err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(bin), nil
+}
+
+func DecodeKeyDerivation(b64 string) (*KeyDerivation, error) {
+	bin, err := base64.URLEncoding.DecodeString(b64)
+	if err != nil {
+		return nil, err
+	}
+
+	var kdf KeyDerivation
+	err = proto.Unmarshal(bin, &kdf)
+	if err != nil {
+		return nil, err
+	}
+	err = validateKeyDerivation(&kdf)
+	if err != nil {
+		return nil, err
+	}
+	return &kdf, nil
+}
@@ -0,0 +1,150 @@
+package bfsp_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/BillysBigFileServer/bfsp-go"
+	"github.com/BillysBigFileServer/bfsp-go/bfsptest"
+	"github.com/BillysBigFileServer/bfsp-go/config"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestSetUpMasterKey(t *testing.T) {
+	cfg := &config.Config{}
+	masterKey, err := bfsp.SetUpMasterKey(cfg, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.KeyDerivation == "" {
+		t.Fatal("the key derivation wasn't saved in the config")
+	}
+
+	kdf, err := bfsp.ConfigKeyDerivation(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rederivedKey, err := bfsp.DeriveMasterKey("password", kdf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(rederivedKey, masterKey) {
+		t.Fatal("the saved derivation derives a different key")
+	}
+
+	// another user with the same password gets their own salt, so their own key
+	otherKey, err := bfsp.SetUpMasterKey(&config.Config{}, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(otherKey, masterKey) {
+		t.Fatal("two users with the same password got the same master key")
+	}
+}
+
+func TestConfigKeyDerivationLegacy(t *testing.T) {
+	kdf, err := bfsp.ConfigKeyDerivation(&config.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	masterKey, err := bfsp.DeriveMasterKey("password", kdf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacyKey, err := bfsp.CreateMasterEncKey("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(masterKey, legacyKey) {
+		t.Fatal("a config without a derivation doesn't derive the legacy key")
+	}
+}
+
+func TestKeyDerivationLimits(t *testing.T) {
+	tests := map[string]func(kdf *bfsp.KeyDerivation){
+		"memory":     func(kdf *bfsp.KeyDerivation) { kdf.MCost = 1 << 30 },
+		"iterations": func(kdf *bfsp.KeyDerivation) { kdf.TCost = 1 << 20 },
+		"short salt": func(kdf *bfsp.KeyDerivation) { kdf.Salt = kdf.Salt[:4] },
+		"algorithm":  func(kdf *bfsp.KeyDerivation) { kdf.Algorithm = "scrypt" },
+	}
+	for name, modify := range tests {
+		t.Run(name, func(t *testing.T) {
+			kdf, err := bfsp.NewKeyDerivation()
+			if err != nil {
+				t.Fatal(err)
+			}
+			modify(kdf)
+
+			_, err = bfsp.DeriveMasterKey("password", kdf)
+			if err == nil {
+				t.Fatal("expected an error deriving with an invalid derivation")
+			}
+			encodedKDF, err := bfsp.EncodeKeyDerivation(kdf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = bfsp.DecodeKeyDerivation(encodedKDF)
+			if err == nil {
+				t.Fatal("expected an error decoding an invalid derivation")
+			}
+		})
+	}
+}
+
+func TestFetchMasterKey(t *testing.T) {
+	srv := bfsptest.NewServer()
+	cli := srv.Client()
+	masterKey, err := bfsp.CreateMasterEncKey("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = bfsp.StoreMasterKey(cli, masterKey, "escrow password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetchedKey, err := bfsp.FetchMasterKey(cli, "escrow password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(fetchedKey, masterKey) {
+		t.Fatal("fetched a different master key")
+	}
+	_, err = bfsp.FetchMasterKey(cli, "wrong password")
+	if !errors.Is(err, bfsp.ErrIncorrectPassword) {
+		t.Fatalf("got error %v for the wrong password, expected ErrIncorrectPassword", err)
+	}
+}
+
+func TestFetchMasterKeyMaliciousKeyDerivation(t *testing.T) {
+	srv := bfsptest.NewServer()
+	kdf, err := bfsp.NewKeyDerivation()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the server picks costs that would take the client 4TiB of memory
+	kdf.MCost = 1<<32 - 1
+	escrowedKey, err := proto.Marshal(&bfsp.EscrowedMasterKey{
+		Kdf:          kdf,
+		Nonce:        make([]byte, 24),
+		EncryptedKey: make([]byte, 48),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = srv.Storage.PutMasterKey(bfsptest.UserID, escrowedKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	_, err = bfsp.FetchMasterKey(srv.Client(), "password")
+	if err == nil {
+		t.Fatal("expected an error for a derivation over the cost limits")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("took %s to refuse the derivation", elapsed)
+	}
+}
@@ -0,0 +1,97 @@
+package bfsp
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+var (
+	ErrMnemonicUnknownWord = errors.New("recovery phrase has a word that isn't in the word list")
+	ErrMnemonicLength      = errors.New("recovery phrase has the wrong number of words")
+	ErrMnemonicChecksum    = errors.New("recovery phrase checksum doesn't match, a word is probably mistyped or out of order")
+)
+
+// the BIP39 english word list. Every word is unique in its first 4 letters
+//
+//go:embed mnemonic_english.txt
+var mnemonicWordList string
+
+var (
+	mnemonicWords       = strings.Fields(mnemonicWordList)
+	mnemonicWordIndices = func() map[string]int {
+		indices := map[string]int{}
+		for i, word := range mnemonicWords {
+			indices[word] = i
+			indices[word[:min(len(word), mnemonicPrefixLength)]] = i
+		}
+		return indices
+	}()
+)
+
+const (
+	mnemonicBitsPerWord  = 11
+	mnemonicPrefixLength = 4
+)
+
+// MasterKeyToMnemonic encodes masterKey as a BIP39 recovery phrase: 24 words for a 32 byte key, the last of which includes a checksum.
+// the phrase is the key itself, so it has to be kept as secret as the key is
+func MasterKeyToMnemonic(masterKey MasterKey) (string, error) {
+	if len(masterKey) < 16 || len(masterKey) > 32 || len(masterKey)%4 != 0 {
+		return "", fmt.Errorf("can't make a recovery phrase for a %d byte key", len(masterKey))
+	}
+
+	// the checksum is the first bit of the key's sha256 for every 32 bits of key
+	checksumBits := len(masterKey) * 8 / 32
+	checksum := sha256.Sum256(masterKey)
+
+	bits := new(big.Int).SetBytes(masterKey)
+	bits.Lsh(bits, uint(checksumBits))
+	bits.Or(bits, big.NewInt(int64(checksum[0]>>(8-checksumBits))))
+
+	wordCount := (len(masterKey)*8 + checksumBits) / mnemonicBitsPerWord
+	words := make([]string, wordCount)
+	wordMask := big.NewInt(1<<mnemonicBitsPerWord - 1)
+	for i := wordCount - 1; i >= 0; i-- {
+		words[i] = mnemonicWords[new(big.Int).And(bits, wordMask).Int64()]
+		bits.Rsh(bits, mnemonicBitsPerWord)
+	}
+
+	return strings.Join(words, " "), nil
+}
+
+// MasterKeyFromMnemonic decodes a recovery phrase from MasterKeyToMnemonic. Case and extra whitespace don't matter, and words can be shortened to their first 4 letters.
+// phrases with a wrong checksum are rejected, so a mistyped phrase is never used as a key
+func MasterKeyFromMnemonic(mnemonic string) (MasterKey, error) {
+	words := strings.Fields(strings.ToLower(mnemonic))
+	if len(words) < 12 || len(words) > 24 || len(words)%3 != 0 {
+		return nil, fmt.Errorf("%w: got %d, expected 12, 15, 18, 21 or 24", ErrMnemonicLength, len(words))
+	}
+
+	bits := new(big.Int)
+	for i, word := range words {
+		index, ok := mnemonicWordIndices[word]
+		if !ok {
+			return nil, fmt.Errorf("%w: word %d, %q", ErrMnemonicUnknownWord, i+1, word)
+		}
+		bits.Lsh(bits, mnemonicBitsPerWord)
+		bits.Or(bits, big.NewInt(int64(index)))
+	}
+
+	checksumBits := len(words) * mnemonicBitsPerWord / 33
+	keySize := checksumBits * 32 / 8
+
+	checksum := new(big.Int).And(bits, big.NewInt(1<<checksumBits-1)).Int64()
+	bits.Rsh(bits, uint(checksumBits))
+	masterKey := bits.FillBytes(make([]byte, keySize))
+
+	expectedChecksum := sha256.Sum256(masterKey)
+	if checksum != int64(expectedChecksum[0]>>(8-checksumBits)) {
+		return nil, ErrMnemonicChecksum
+	}
+
+	return masterKey, nil
+}
@@ -0,0 +1,91 @@
+package bfsp_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/BillysBigFileServer/bfsp-go"
+)
+
+// vectors from the BIP39 reference implementation's vectors.json
+var mnemonicVectors = []struct {
+	key      string
+	mnemonic string
+}{
+	{"00000000000000000000000000000000", "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"},
+	{"7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f", "legal winner thank year wave sausage worth useful legal winner thank yellow"},
+	{"80808080808080808080808080808080", "letter advice cage absurd amount doctor acoustic avoid letter advice cage above"},
+	{"ffffffffffffffffffffffffffffffff", "zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo wrong"},
+	{"9e885d952ad362caeb4efe34a8e91bd2", "ozone drill grab fiber curtain grace pudding thank cruise elder eight picnic"},
+	{"000000000000000000000000000000000000000000000000", "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon agent"},
+	{"0000000000000000000000000000000000000000000000000000000000000000", "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon art"},
+	{"7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f", "legal winner thank year wave sausage worth useful legal winner thank year wave sausage worth useful legal winner thank year wave sausage worth title"},
+	{"8080808080808080808080808080808080808080808080808080808080808080", "letter advice cage absurd amount doctor acoustic avoid letter advice cage absurd amount doctor acoustic avoid letter advice cage absurd amount doctor acoustic bless"},
+	{"ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff", "zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo vote"},
+	{"68a79eaca2324873eacc50cb9c6eca8cc68ea5d936f98787c60c7ebc74e6ce7c", "hamster diagram private dutch cause delay private meat slide toddler razor book happy fancy gospel tennis maple dilemma loan word shrug inflict delay length"},
+}
+
+func TestMnemonicVectors(t *testing.T) {
+	for _, vector := range mnemonicVectors {
+		masterKey, err := hex.DecodeString(vector.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		mnemonic, err := bfsp.MasterKeyToMnemonic(masterKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mnemonic != vector.mnemonic {
+			t.Errorf("key %s encoded as %q, expected %q", vector.key, mnemonic, vector.mnemonic)
+		}
+
+		decodedKey, err := bfsp.MasterKeyFromMnemonic(vector.mnemonic)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(decodedKey, masterKey) {
+			t.Errorf("%q decoded as %x, expected %s", vector.mnemonic, decodedKey, vector.key)
+		}
+	}
+}
+
+func TestMnemonicLenientInput(t *testing.T) {
+	vector := mnemonicVectors[len(mnemonicVectors)-1]
+	shortened := []string{}
+	for _, word := range strings.Fields(vector.mnemonic) {
+		shortened = append(shortened, strings.ToUpper(word[:min(len(word), 4)]))
+	}
+
+	masterKey, err := bfsp.MasterKeyFromMnemonic("  " + strings.Join(shortened, "\n ") + "\t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(masterKey) != vector.key {
+		t.Fatalf("shortened phrase decoded as %x, expected %s", masterKey, vector.key)
+	}
+}
+
+func TestMnemonicErrors(t *testing.T) {
+	tests := map[string]struct {
+		mnemonic string
+		err      error
+	}{
+		// "about" is the only last word with the right checksum for an all zero key
+		"checksum":     {strings.Repeat("abandon ", 11) + "abandon", bfsp.ErrMnemonicChecksum},
+		"unknown word": {strings.Repeat("abandon ", 11) + "bitcoins", bfsp.ErrMnemonicUnknownWord},
+		"too short":    {strings.Repeat("abandon ", 10) + "about", bfsp.ErrMnemonicLength},
+		"too long":     {strings.Repeat("abandon ", 24) + "art", bfsp.ErrMnemonicLength},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := bfsp.MasterKeyFromMnemonic(test.mnemonic)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("got error %v, expected %v", err, test.err)
+			}
+		})
+	}
+}
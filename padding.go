@@ -0,0 +1,48 @@
+package bfsp
+
+import (
+	"encoding/binary"
+	"math/bits"
+	"slices"
+)
+
+// padded compressed data ends with a zstd skippable frame, which decoders ignore. So nothing reading it back has to know it was padded
+const (
+	zstdSkippableFrameMagic      = 0x184D2A50
+	zstdSkippableFrameHeaderSize = 8
+)
+
+// padmeSize rounds size up to the next Padmé bucket. The result only gives away O(log log size) bits about size, and is at most ~12% bigger
+func padmeSize(size uint64) uint64 {
+	if size < 2 {
+		return size
+	}
+
+	exponent := uint64(bits.Len64(size) - 1)
+	exponentBits := uint64(bits.Len64(exponent))
+	lastBits := exponent - exponentBits
+	mask := uint64(1)<<lastBits - 1
+	return (size + mask) &^ mask
+}
+
+// padCompressed pads zstd compressed data to a Padmé size. Like append, it only allocates if compressed doesn't have the capacity
+func padCompressed(compressed []byte) []byte {
+	paddedSize := padmeSize(uint64(len(compressed)) + zstdSkippableFrameHeaderSize)
+	paddingSize := paddedSize - uint64(len(compressed)) - zstdSkippableFrameHeaderSize
+
+	padded := slices.Grow(compressed, int(paddedSize)-len(compressed))
+	padded = binary.LittleEndian.AppendUint32(padded, zstdSkippableFrameMagic)
+	padded = binary.LittleEndian.AppendUint32(padded, uint32(paddingSize))
+	// the padding has to be zeroed, the buffer could have been used before
+	clear(padded[len(padded):paddedSize])
+	return padded[:paddedSize]
+}
+
+// padUncompressed pads data that's stored uncompressed to a Padmé size with zeros. Its real size has to be stored somewhere else
+func padUncompressed(data []byte) []byte {
+	paddedSize := int(padmeSize(uint64(len(data))))
+
+	padded := slices.Grow(data, paddedSize-len(data))
+	clear(padded[len(padded):paddedSize])
+	return padded[:paddedSize]
+}
@@ -0,0 +1,51 @@
+package bfsp
+
+import (
+	"crypto/rand"
+	"testing"
+)
+
+func TestPadmeSize(t *testing.T) {
+	tests := map[uint64]uint64{
+		0:    0,
+		1:    1,
+		9:    10,
+		1000: 1024,
+		4000: 4096,
+		4097: 4352,
+	}
+	for size, expected := range tests {
+		if paddedSize := padmeSize(size); paddedSize != expected {
+			t.Errorf("padmeSize(%d) = %d, expected %d", size, paddedSize, expected)
+		}
+	}
+}
+
+func TestCompressChunkPadded(t *testing.T) {
+	// compresses to a little under its size, but padding rounds it back up to exactly its size
+	chunk := make([]byte, 4096)
+	_, err := rand.Read(chunk[100:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compressed, uncompressed, err := compressChunk(chunk, CompressionDefault, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer putCompressBuffer(compressed)
+	if uncompressed {
+		t.Fatal("chunk that compressed was stored uncompressed because of its padding")
+	}
+	if uint64(len(compressed)) != padmeSize(uint64(len(compressed))) {
+		t.Fatalf("compressed chunk is %d bytes, which isn't a padded size", len(compressed))
+	}
+
+	decompressed, err := decompress(compressed, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(decompressed) != string(chunk) {
+		t.Fatal("decompressed chunk doesn't match")
+	}
+}
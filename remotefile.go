@@ -0,0 +1,296 @@
+package bfsp
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"sync"
+)
+
+const (
+	// how many decrypted chunks a RemoteFile keeps in memory
+	remoteFileCacheSize = 8
+	// how many chunks past the current one are fetched in the background while a RemoteFile is read sequentially
+	remoteFileReadahead = 2
+)
+
+// RemoteFile reads a file stored on the file server at arbitrary offsets, only downloading the chunks it needs
+type RemoteFile struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	client   FileServerClient
+	codec    *chunkCodec
+	fileMeta *FileMetadata
+	token    string
+
+	// chunkIndices[i] is the indice of the i'th chunk of the file, which starts at offsets[i]. offsets has one more entry, the size of the file
+	chunkIndices []uint64
+	offsets      []int64
+
+	mu       sync.Mutex
+	cache    *chunkCache
+	inflight map[int]*chunkFetch
+	lastRead int
+	closed   bool
+
+	// the position Read and Seek work from
+	offset int64
+}
+
+type chunkFetch struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+// NewRemoteFile creates a RemoteFile for fileMeta, using the client and master key in ctx. token is used for downloading chunks if it's not empty, like in DownloadFile
+func NewRemoteFile(ctx context.Context, fileMeta *FileMetadata, token string) (*RemoteFile, error) {
+	chunkIndices := []uint64{}
+	for chunkIndice := range fileMeta.Chunks {
+		chunkIndices = append(chunkIndices, chunkIndice)
+	}
+	sort.Slice(chunkIndices, func(i, j int) bool { return chunkIndices[i] < chunkIndices[j] })
+
+	offsets := make([]int64, len(chunkIndices)+1)
+	for i, chunkIndice := range chunkIndices {
+		size, err := remoteChunkSize(fileMeta, chunkIndice, i, len(chunkIndices))
+		if err != nil {
+			return nil, err
+		}
+		offsets[i+1] = offsets[i] + int64(size)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	return &RemoteFile{
+		ctx:          ctx,
+		cancel:       cancel,
+		client:       ClientFromContext(ctx).withContext(ctx),
+		codec:        newChunkCodec(MasterKeyFromContext(ctx)),
+		fileMeta:     fileMeta,
+		token:        token,
+		chunkIndices: chunkIndices,
+		offsets:      offsets,
+		cache:        newChunkCache(remoteFileCacheSize),
+		inflight:     map[int]*chunkFetch{},
+		lastRead:     -1,
+	}, nil
+}
+
+// remoteChunkSize returns the size of the chunk at position i of n.
+// files uploaded before FileMetadata.ChunkSizes existed were always split every ChunkSize bytes, so their sizes can be worked out from the file size
+func remoteChunkSize(fileMeta *FileMetadata, chunkIndice uint64, i int, n int) (uint32, error) {
+	if len(fileMeta.ChunkSizes) > 0 {
+		size, ok := fileMeta.ChunkSizes[chunkIndice]
+		if !ok {
+			return 0, fmt.Errorf("no size for chunk %d", chunkIndice)
+		}
+		return size, nil
+	}
+	if fileMeta.ContentAddressed {
+		return 0, fmt.Errorf("file %s has no chunk sizes", fileMeta.Id)
+	}
+
+	if i < n-1 {
+		return ChunkSize, nil
+	}
+	lastSize := int64(fileMeta.FileSize) - int64(n-1)*ChunkSize
+	if lastSize <= 0 || lastSize > ChunkSize {
+		return 0, fmt.Errorf("file size %d doesn't fit %d chunks", fileMeta.FileSize, n)
+	}
+	return uint32(lastSize), nil
+}
+
+// Size returns the size of the file in bytes
+func (f *RemoteFile) Size() int64 {
+	return f.offsets[len(f.offsets)-1]
+}
+
+func (f *RemoteFile) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		if pos >= f.Size() {
+			return n, io.EOF
+		}
+
+		// the last chunk starting at or before pos
+		i := sort.Search(len(f.chunkIndices), func(i int) bool { return f.offsets[i+1] > pos })
+		chunk, err := f.chunk(i)
+		if err != nil {
+			return n, err
+		}
+		n += copy(p[n:], chunk[pos-f.offsets[i]:])
+	}
+
+	return n, nil
+}
+
+func (f *RemoteFile) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	n, err := f.ReadAt(p, f.offset)
+	f.offset += int64(n)
+	if n > 0 && errors.Is(err, io.EOF) {
+		err = nil
+	}
+	return n, err
+}
+
+func (f *RemoteFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.Size()
+	default:
+		return 0, fmt.Errorf("invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	f.offset = offset
+	return offset, nil
+}
+
+// Close stops any background downloads and frees the cached chunks
+func (f *RemoteFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return fs.ErrClosed
+	}
+	f.closed = true
+	f.cancel()
+	f.cache = newChunkCache(0)
+	return nil
+}
+
+// chunk returns the decrypted chunk at position i, from the cache if possible
+func (f *RemoteFile) chunk(i int) ([]byte, error) {
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return nil, fs.ErrClosed
+	}
+
+	data, cached := f.cache.get(i)
+	var fetch *chunkFetch
+	if !cached {
+		fetch = f.fetch(i)
+	}
+
+	// reading the same or the next chunk as last time looks like a sequential read, so the chunks after it will probably be wanted soon
+	if i == f.lastRead || i == f.lastRead+1 {
+		for ahead := i + 1; ahead <= i+remoteFileReadahead && ahead < len(f.chunkIndices); ahead++ {
+			if _, ok := f.cache.get(ahead); !ok {
+				f.fetch(ahead)
+			}
+		}
+	}
+	f.lastRead = i
+	f.mu.Unlock()
+
+	if cached {
+		return data, nil
+	}
+	select {
+	case <-fetch.done:
+		return fetch.data, fetch.err
+	case <-f.ctx.Done():
+		return nil, f.ctx.Err()
+	}
+}
+
+// fetch starts downloading the chunk at position i, unless it's already being downloaded. f.mu must be held
+func (f *RemoteFile) fetch(i int) *chunkFetch {
+	if fetch, ok := f.inflight[i]; ok {
+		return fetch
+	}
+
+	fetch := &chunkFetch{done: make(chan struct{})}
+	f.inflight[i] = fetch
+	go func() {
+		chunkIndice := f.chunkIndices[i]
+		data, err := f.codec.downloadChunk(f.client, DownloadChunkArgs{
+			ChunkID:          f.fileMeta.Chunks[chunkIndice],
+			FileID:           f.fileMeta.Id,
+			Indice:           chunkIndice,
+			Size:             uint32(f.offsets[i+1] - f.offsets[i]),
+			Token:            f.token,
+			ContentAddressed: f.fileMeta.ContentAddressed,
+			WrappedDataKey:   f.fileMeta.WrappedDataKey,
+		})
+		if err == nil && int64(len(data)) != f.offsets[i+1]-f.offsets[i] {
+			err = fmt.Errorf("chunk %d is %d bytes, expected %d", chunkIndice, len(data), f.offsets[i+1]-f.offsets[i])
+		}
+
+		f.mu.Lock()
+		delete(f.inflight, i)
+		// failed downloads aren't cached, so the next read tries again
+		if err == nil {
+			f.cache.add(i, data)
+		}
+		f.mu.Unlock()
+
+		fetch.data, fetch.err = data, err
+		close(fetch.done)
+	}()
+	return fetch
+}
+
+// chunkCache is a least recently used cache of decrypted chunks, by position in the file
+type chunkCache struct {
+	capacity int
+	order    *list.List
+	entries  map[int]*list.Element
+}
+
+type chunkCacheEntry struct {
+	i    int
+	data []byte
+}
+
+func newChunkCache(capacity int) *chunkCache {
+	return &chunkCache{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  map[int]*list.Element{},
+	}
+}
+
+func (c *chunkCache) get(i int) ([]byte, bool) {
+	elem, ok := c.entries[i]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*chunkCacheEntry).data, true
+}
+
+func (c *chunkCache) add(i int, data []byte) {
+	if elem, ok := c.entries[i]; ok {
+		c.order.MoveToFront(elem)
+		elem.Value.(*chunkCacheEntry).data = data
+		return
+	}
+
+	c.entries[i] = c.order.PushFront(&chunkCacheEntry{i: i, data: data})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*chunkCacheEntry).i)
+	}
+}
@@ -0,0 +1,263 @@
+package bfsp_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"runtime"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/BillysBigFileServer/bfsp-go"
+)
+
+// waitFor waits up to a second for cond to be true
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRemoteFileReads(t *testing.T) {
+	ctx, _, _ := newChunkServerContext(t)
+	// the last chunk is short
+	data := randomBytes(t, 3*bfsp.ChunkSize-100)
+	res, err := bfsp.UploadFile(ctx, &bfsp.FileInfo{Name: "file", Reader: bytes.NewReader(data)}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := bfsp.NewRemoteFile(ctx, res.FileMetadata, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if f.Size() != int64(len(data)) {
+		t.Fatalf("got size %d, expected %d", f.Size(), len(data))
+	}
+
+	// Read, ReadAt and Seek, including reads that cross chunks and ones at or past the end
+	err = iotest.TestReader(f, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		off     int64
+		size    int
+		n       int
+		wantErr error
+	}{
+		"across chunks":     {bfsp.ChunkSize - 10, 20, 20, nil},
+		"across all chunks": {5, len(data) - 10, len(data) - 10, nil},
+		"up to the end":     {int64(len(data)) - 20, 20, 20, nil},
+		"past the end":      {int64(len(data)) - 10, 20, 10, io.EOF},
+		"at the end":        {int64(len(data)), 20, 0, io.EOF},
+		"after the end":     {int64(len(data)) + 5, 20, 0, io.EOF},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := make([]byte, test.size)
+			n, err := f.ReadAt(p, test.off)
+			if n != test.n || err != test.wantErr {
+				t.Fatalf("ReadAt returned %d, %v, expected %d, %v", n, err, test.n, test.wantErr)
+			}
+			if n > 0 && !bytes.Equal(p[:n], data[test.off:test.off+int64(n)]) {
+				t.Fatal("read the wrong data")
+			}
+		})
+	}
+
+	_, err = f.ReadAt(make([]byte, 1), -1)
+	if err == nil {
+		t.Fatal("read at a negative offset")
+	}
+}
+
+func TestRemoteFileSeek(t *testing.T) {
+	ctx, _, fileMeta, data := uploadChunkServerFile(t, 2)
+	f, err := bfsp.NewRemoteFile(ctx, fileMeta, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	seeks := []struct {
+		offset int64
+		whence int
+		pos    int64
+	}{
+		{bfsp.ChunkSize - 2, io.SeekStart, bfsp.ChunkSize - 2},
+		{4, io.SeekCurrent, bfsp.ChunkSize + 6},
+		{-8, io.SeekCurrent, bfsp.ChunkSize + 2},
+		{-3, io.SeekEnd, int64(len(data)) - 3},
+	}
+	for _, seek := range seeks {
+		pos, err := f.Seek(seek.offset, seek.whence)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pos != seek.pos {
+			t.Fatalf("seek(%d, %d) went to %d, expected %d", seek.offset, seek.whence, pos, seek.pos)
+		}
+		// reading moves the position on, which the next seek is relative to
+		p := make([]byte, 4)
+		n, err := f.Read(p)
+		if err != nil && err != io.EOF {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(p[:n], data[pos:min(pos+4, int64(len(data)))]) {
+			t.Fatalf("read the wrong data after seeking to %d", pos)
+		}
+	}
+
+	_, err = f.Seek(0, io.SeekEnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := f.Read(make([]byte, 4))
+	if n != 0 || err != io.EOF {
+		t.Fatalf("Read at the end returned %d, %v, expected 0, EOF", n, err)
+	}
+
+	if _, err := f.Seek(-1, io.SeekStart); err == nil {
+		t.Fatal("seeked to a negative position")
+	}
+	if _, err := f.Seek(0, 3); err == nil {
+		t.Fatal("seeked with an invalid whence")
+	}
+}
+
+func TestRemoteFileCache(t *testing.T) {
+	ctx, srv, fileMeta, data := uploadChunkServerFile(t, 2*bfsp.RemoteFileCacheSize+4)
+	srv.beforeDownload = func(string) error { return nil }
+	f, err := bfsp.NewRemoteFile(ctx, fileMeta, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	readChunk := func(i int) {
+		t.Helper()
+		p := make([]byte, 10)
+		_, err := f.ReadAt(p, int64(i)*bfsp.ChunkSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(p, data[i*bfsp.ChunkSize:i*bfsp.ChunkSize+10]) {
+			t.Fatalf("read the wrong data from chunk %d", i)
+		}
+	}
+
+	// every other chunk, so the reads don't look sequential and nothing is read ahead
+	for i := 0; i <= bfsp.RemoteFileCacheSize; i++ {
+		readChunk(2*i + 2)
+	}
+	if downloads := srv.downloads.Load(); downloads != bfsp.RemoteFileCacheSize+1 {
+		t.Fatalf("%d chunks were downloaded, expected %d", downloads, bfsp.RemoteFileCacheSize+1)
+	}
+
+	// the first chunk read was the least recently used, so it's the one that was dropped
+	readChunk(4)
+	if downloads := srv.downloads.Load(); downloads != bfsp.RemoteFileCacheSize+1 {
+		t.Fatal("a cached chunk was downloaded again")
+	}
+	readChunk(2)
+	if downloads := srv.downloads.Load(); downloads != bfsp.RemoteFileCacheSize+2 {
+		t.Fatal("the least recently used chunk was still cached")
+	}
+}
+
+func TestRemoteFileReadahead(t *testing.T) {
+	ctx, srv, fileMeta, _ := uploadChunkServerFile(t, bfsp.RemoteFileReadahead+4)
+	srv.beforeDownload = func(string) error { return nil }
+	f, err := bfsp.NewRemoteFile(ctx, fileMeta, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	_, err = f.Read(make([]byte, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// reading from the start looks sequential, so the next chunks are downloaded in the background
+	waitFor(t, func() bool { return srv.downloads.Load() == 1+bfsp.RemoteFileReadahead })
+
+	// they're cached once they land, so reading them doesn't download anything more than the next readahead
+	_, err = f.Seek(bfsp.ChunkSize, io.SeekStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.Read(make([]byte, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return srv.downloads.Load() == 2+bfsp.RemoteFileReadahead })
+}
+
+func TestRemoteFileClose(t *testing.T) {
+	// only the chunks read ahead after the first, so reading the second can't read any more ahead
+	ctx, srv, fileMeta, _ := uploadChunkServerFile(t, bfsp.RemoteFileReadahead+1)
+	indices := chunkIndices(fileMeta)
+	release := make(chan struct{})
+	srv.beforeDownload = func(chunkID string) error {
+		if indices[chunkID] != 0 {
+			<-release
+		}
+		return nil
+	}
+	goroutines := runtime.NumGoroutine()
+
+	f, err := bfsp.NewRemoteFile(ctx, fileMeta, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.Read(make([]byte, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return srv.inFlight.Load() == bfsp.RemoteFileReadahead })
+
+	// a read waiting on a chunk that's being read ahead gives up as soon as the file is closed
+	readErr := make(chan error)
+	go func() {
+		_, err := f.ReadAt(make([]byte, 10), bfsp.ChunkSize)
+		readErr <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	err = f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-readErr:
+		if err == nil {
+			t.Fatal("read a chunk after the file was closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("read didn't return after the file was closed")
+	}
+
+	_, err = f.Read(make([]byte, 10))
+	if !errors.Is(err, fs.ErrClosed) {
+		t.Fatalf("got error %v reading a closed file, expected fs.ErrClosed", err)
+	}
+	if err := f.Close(); !errors.Is(err, fs.ErrClosed) {
+		t.Fatalf("got error %v closing twice, expected fs.ErrClosed", err)
+	}
+
+	// the downloads that were read ahead finish, and nothing more is downloaded
+	close(release)
+	waitFor(t, func() bool { return runtime.NumGoroutine() <= goroutines })
+	if downloads := srv.downloads.Load(); downloads != 1+bfsp.RemoteFileReadahead {
+		t.Fatalf("%d chunks were downloaded, expected %d", downloads, 1+bfsp.RemoteFileReadahead)
+	}
+}
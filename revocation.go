@@ -0,0 +1,230 @@
+package bfsp
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"google.golang.org/protobuf/proto"
+	"lukechampine.com/blake3"
+)
+
+const shareRevocationsAdditionalData = "bfsp share revocations"
+
+// ShareRevocationsID is the id the share revocation list is stored under as file metadata, the same for every user.
+// share tokens only ever allow the ids of the files they share, so a share can't touch it
+const ShareRevocationsID = "e6ca9a3b-3dd6-4276-8d4a-8d722f3418fb"
+
+// the share revocation list is stored as file metadata starting with this, then a serialized ShareRevocationList, so it's never mistaken for a file's
+const shareRevocationsMagic = "bfsprev1"
+
+// deriveShareRevocationsKey derives the key ShareRevocationList.EncryptedRevocations is encrypted with
+func deriveShareRevocationsKey(masterKey MasterKey) []byte {
+	key := make([]byte, chacha20poly1305.KeySize)
+	blake3.DeriveKey(key, "bfsp share revocations key", masterKey)
+	return key
+}
+
+// RevokeShare revokes a share made with WithShareID, so servers that enforce the revocation list, like the one in package server, reject its token from now on.
+// the list is a single blob the server can only replace as a whole. Updates from this process are serialized and checked after they're written, but the server can't
+// update it conditionally, so a revocation from another device at the same moment can still overwrite this one. Check ListShareRevocations if that matters
+func RevokeShare(cli FileServerClient, shareID string, fileID string, masterKey MasterKey) error {
+	if shareID == "" {
+		return errors.New("can't revoke a share without a share id")
+	}
+
+	return updateShareRevocations(cli, masterKey, func(revocations []*ShareRevocation) ([]*ShareRevocation, bool) {
+		for _, revocation := range revocations {
+			if revocation.ShareId == shareID {
+				return nil, false
+			}
+		}
+		return append(revocations, &ShareRevocation{
+			ShareId:   shareID,
+			FileId:    fileID,
+			RevokedAt: time.Now().Unix(),
+		}), true
+	})
+}
+
+// UnrevokeShare undoes RevokeShare, so the share's token works again until it expires. It has the same limits as RevokeShare
+func UnrevokeShare(cli FileServerClient, shareID string, masterKey MasterKey) error {
+	return updateShareRevocations(cli, masterKey, func(revocations []*ShareRevocation) ([]*ShareRevocation, bool) {
+		remaining := []*ShareRevocation{}
+		for _, revocation := range revocations {
+			if revocation.ShareId != shareID {
+				remaining = append(remaining, revocation)
+			}
+		}
+		return remaining, len(remaining) != len(revocations)
+	})
+}
+
+// how many times updateShareRevocations writes the list before giving up on the change sticking
+const shareRevocationsAttempts = 5
+
+// serializes updateShareRevocations, so concurrent revocations in this process can't overwrite each other
+var shareRevocationsMu sync.Mutex
+
+// updateShareRevocations applies update to the stored revocation list. update returns the new list, and false if the list doesn't need changing.
+// the list is read back after it's written, and the update applied again if a write from somewhere else replaced it in between
+func updateShareRevocations(cli FileServerClient, masterKey MasterKey, update func(revocations []*ShareRevocation) ([]*ShareRevocation, bool)) error {
+	shareRevocationsMu.Lock()
+	defer shareRevocationsMu.Unlock()
+
+	for attempt := 0; ; attempt++ {
+		revocations, err := ListShareRevocations(cli, masterKey)
+		if err != nil {
+			return err
+		}
+		revocations, changed := update(revocations)
+		if !changed {
+			return nil
+		}
+		if attempt == shareRevocationsAttempts {
+			return errors.New("share revocations kept being replaced while updating them")
+		}
+		err = setShareRevocations(cli, revocations, masterKey)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// ListShareRevocations returns every share revoked with RevokeShare
+func ListShareRevocations(cli FileServerClient, masterKey MasterKey) ([]*ShareRevocation, error) {
+	revocationList, err := getShareRevocations(cli)
+	if err != nil {
+		return nil, err
+	}
+	return decryptShareRevocations(revocationList, masterKey)
+}
+
+func decryptShareRevocations(revocationList *ShareRevocationList, masterKey MasterKey) ([]*ShareRevocation, error) {
+	if len(revocationList.EncryptedRevocations) == 0 {
+		return []*ShareRevocation{}, nil
+	}
+
+	enc, err := chacha20poly1305.NewX(deriveShareRevocationsKey(masterKey))
+	if err != nil {
+		return nil, err
+	}
+	if len(revocationList.EncryptedRevocations) < enc.NonceSize() {
+		return nil, fmt.Errorf("share revocations are too short")
+	}
+	nonce := revocationList.EncryptedRevocations[:enc.NonceSize()]
+	revocationsBin, err := enc.Open(nil, nonce, revocationList.EncryptedRevocations[enc.NonceSize():], []byte(shareRevocationsAdditionalData))
+	if err != nil {
+		return nil, err
+	}
+
+	var revocations ShareRevocations
+	err = proto.Unmarshal(revocationsBin, &revocations)
+	if err != nil {
+		return nil, err
+	}
+	return revocations.Revocations, nil
+}
+
+// encryptShareRevocations builds the ShareRevocationList for revocations. The server only gets to see their share ids
+func encryptShareRevocations(revocations []*ShareRevocation, masterKey MasterKey) (*ShareRevocationList, error) {
+	revocationsBin, err := proto.Marshal(&ShareRevocations{Revocations: revocations})
+	if err != nil {
+		return nil, err
+	}
+
+	enc, err := chacha20poly1305.NewX(deriveShareRevocationsKey(masterKey))
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, enc.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	revokedShareIDs := []string{}
+	for _, revocation := range revocations {
+		revokedShareIDs = append(revokedShareIDs, revocation.ShareId)
+	}
+	return &ShareRevocationList{
+		RevokedShareIds:      revokedShareIDs,
+		EncryptedRevocations: enc.Seal(nonce, nonce, revocationsBin, []byte(shareRevocationsAdditionalData)),
+	}, nil
+}
+
+// rotateShareRevocations re-encrypts the share revocation list with newKey, unless an earlier attempt already did
+func rotateShareRevocations(cli FileServerClient, oldKey MasterKey, newKey MasterKey) error {
+	revocationList, err := getShareRevocations(cli)
+	if err != nil {
+		return err
+	}
+
+	revocations, err := decryptShareRevocations(revocationList, oldKey)
+	if err != nil {
+		if _, newErr := decryptShareRevocations(revocationList, newKey); newErr == nil {
+			return nil
+		}
+		return fmt.Errorf("share revocations can't be decrypted with either key: %w", err)
+	}
+	if len(revocations) == 0 {
+		return nil
+	}
+	return setShareRevocations(cli, revocations, newKey)
+}
+
+func setShareRevocations(cli FileServerClient, revocations []*ShareRevocation, masterKey MasterKey) error {
+	revocationList, err := encryptShareRevocations(revocations, masterKey)
+	if err != nil {
+		return err
+	}
+	revocationListBin, err := proto.Marshal(revocationList)
+	if err != nil {
+		return err
+	}
+	encFileMeta := &EncryptedFileMetadata{
+		Id:       ShareRevocationsID,
+		Metadata: append([]byte(shareRevocationsMagic), revocationListBin...),
+	}
+
+	stored, err := listStoredMetadata(cli, []string{ShareRevocationsID})
+	if err != nil {
+		return err
+	}
+	if _, ok := stored[ShareRevocationsID]; ok {
+		return updateEncryptedFileMetadata(cli, encFileMeta)
+	}
+	return uploadEncryptedFileMetadata(cli, encFileMeta)
+}
+
+// getShareRevocations returns the stored share revocation list, which is empty if no share has ever been revoked
+func getShareRevocations(cli FileServerClient) (*ShareRevocationList, error) {
+	stored, err := listStoredMetadata(cli, []string{ShareRevocationsID})
+	if err != nil {
+		return nil, err
+	}
+	encFileMeta, ok := stored[ShareRevocationsID]
+	if !ok {
+		return &ShareRevocationList{}, nil
+	}
+	return ParseShareRevocationList(encFileMeta)
+}
+
+// ParseShareRevocationList parses the share revocation list stored as file metadata with ShareRevocationsID, for servers that enforce it
+func ParseShareRevocationList(encFileMeta *EncryptedFileMetadata) (*ShareRevocationList, error) {
+	revocationListBin, ok := bytes.CutPrefix(encFileMeta.Metadata, []byte(shareRevocationsMagic))
+	if !ok {
+		return nil, errors.New("file metadata isn't a share revocation list")
+	}
+
+	var revocationList ShareRevocationList
+	err := proto.Unmarshal(revocationListBin, &revocationList)
+	if err != nil {
+		return nil, err
+	}
+	return &revocationList, nil
+}
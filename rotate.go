@@ -0,0 +1,513 @@
+package bfsp
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/google/uuid"
+	"golang.org/x/sync/errgroup"
+	"lukechampine.com/blake3"
+)
+
+var ErrRotationKeyMismatch = errors.New("rotation journal is for different keys")
+var ErrRotationModeMismatch = errors.New("rotation journal was started with a different WithFullReKey")
+
+const rotationJournalName = "master-key-rotation.journal"
+
+// how many chunks of a file RotateMasterKey re-encrypts at once
+const rotateConcurrency = 8
+
+// the first line of a rotation journal. Every line after it is a rotationEntry
+type rotationHeader struct {
+	OldKeyID  string `json:"old_key_id"`
+	NewKeyID  string `json:"new_key_id"`
+	FullReKey bool   `json:"full_re_key,omitempty"`
+}
+
+// rotationEntry is either a chunk that's been re-encrypted and uploaded, the data key a file's chunks are being re-encrypted with, or a file whose metadata has been swapped to the new key
+type rotationEntry struct {
+	FileID         string `json:"file_id"`
+	Indice         uint64 `json:"indice,omitempty"`
+	OldChunkID     string `json:"old_chunk_id,omitempty"`
+	NewChunkID     string `json:"new_chunk_id,omitempty"`
+	Size           uint32 `json:"size,omitempty"`
+	WrappedDataKey []byte `json:"wrapped_data_key,omitempty"`
+	Rotated        bool   `json:"rotated,omitempty"`
+}
+
+// rotationJournal records a master key rotation on disk, so that an interrupted rotation doesn't have to re-encrypt files it already finished
+type rotationJournal struct {
+	// chunks[fileID][indice] is the re-encrypted copy of a chunk
+	chunks map[string]map[uint64]*rotationEntry
+	// dataKeys[fileID] is the new data key, wrapped with the new master key, a file's chunks are being re-encrypted with
+	dataKeys map[string][]byte
+	rotated  map[string]bool
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+// masterKeyID identifies a master key in a rotation journal without giving it away
+func masterKeyID(masterKey MasterKey) string {
+	return hex.EncodeToString(MasterKeyFingerprint(masterKey))
+}
+
+func openRotationJournal(journalDir string, oldKey MasterKey, newKey MasterKey, fullReKey bool) (*rotationJournal, error) {
+	err := os.MkdirAll(journalDir, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	header := rotationHeader{
+		OldKeyID:  masterKeyID(oldKey),
+		NewKeyID:  masterKeyID(newKey),
+		FullReKey: fullReKey,
+	}
+	journal := &rotationJournal{
+		chunks:   map[string]map[uint64]*rotationEntry{},
+		dataKeys: map[string][]byte{},
+		rotated:  map[string]bool{},
+	}
+
+	path := filepath.Join(journalDir, rotationJournalName)
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		headerJson, err := json.Marshal(header)
+		if err != nil {
+			return nil, err
+		}
+		journal.file, err = os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return nil, err
+		}
+		_, err = journal.file.Write(append(headerJson, '\n'))
+		if err != nil {
+			journal.file.Close()
+			return nil, err
+		}
+		return journal, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	if !scanner.Scan() {
+		if scanner.Err() != nil {
+			return nil, scanner.Err()
+		}
+		return nil, fmt.Errorf("rotation journal %s is empty", path)
+	}
+	var journalHeader rotationHeader
+	err = json.Unmarshal(scanner.Bytes(), &journalHeader)
+	if err != nil {
+		return nil, err
+	}
+	if journalHeader.OldKeyID != header.OldKeyID || journalHeader.NewKeyID != header.NewKeyID {
+		return nil, ErrRotationKeyMismatch
+	}
+	if journalHeader.FullReKey != header.FullReKey {
+		return nil, ErrRotationModeMismatch
+	}
+
+	for scanner.Scan() {
+		var entry rotationEntry
+		err = json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			// cut off by a crash, the chunk just gets re-encrypted again
+			continue
+		}
+		if entry.Rotated {
+			journal.rotated[entry.FileID] = true
+			continue
+		}
+		if entry.WrappedDataKey != nil {
+			journal.dataKeys[entry.FileID] = entry.WrappedDataKey
+			continue
+		}
+		if journal.chunks[entry.FileID] == nil {
+			journal.chunks[entry.FileID] = map[uint64]*rotationEntry{}
+		}
+		journal.chunks[entry.FileID][entry.Indice] = &entry
+	}
+	if scanner.Err() != nil {
+		return nil, scanner.Err()
+	}
+
+	journal.file, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	// make sure a cut off line doesn't swallow the next one
+	_, err = journal.file.Write([]byte{'\n'})
+	if err != nil {
+		journal.file.Close()
+		return nil, err
+	}
+
+	return journal, nil
+}
+
+func (j *rotationJournal) record(entry *rotationEntry) error {
+	entryJson, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	_, err = j.file.Write(append(entryJson, '\n'))
+	return err
+}
+
+func (j *rotationJournal) rotatedChunks(fileID string) map[uint64]*rotationEntry {
+	if j.chunks[fileID] == nil {
+		return map[uint64]*rotationEntry{}
+	}
+	return j.chunks[fileID]
+}
+
+func (j *rotationJournal) dataKey(fileID string) []byte {
+	return j.dataKeys[fileID]
+}
+
+func (j *rotationJournal) close() error {
+	return j.file.Close()
+}
+
+// remove deletes the journal once the rotation is finished
+func (j *rotationJournal) remove() error {
+	err := j.file.Close()
+	if err != nil && !errors.Is(err, os.ErrClosed) {
+		return err
+	}
+	return os.Remove(j.file.Name())
+}
+
+type rotateOptions struct {
+	fullReKey bool
+}
+
+type RotateOption func(*rotateOptions)
+
+// WithFullReKey gives every file a new data key and re-encrypts all of its chunks with it, instead of just rewrapping the data keys files already have.
+// rewrapping alone leaves chunks encrypted with the same data keys, so anyone who got one (from a leaked master key, or a share, which holds its file's data key) can still read the file.
+// use this when a key might have leaked, it costs downloading and uploading every file
+func WithFullReKey() RotateOption {
+	return func(opts *rotateOptions) {
+		opts.fullReKey = true
+	}
+}
+
+// RotateMasterKey moves every file's metadata and chunks from oldKey to newKey, using the client in ctx.
+// files with a data key just have it rewrapped, unless WithFullReKey is passed. Rewrapping doesn't recover from a leaked master key or data key, see WithFullReKey.
+// other files' chunks are uploaded again under new ids and a new data key, then the metadata is swapped and the old chunks are deleted, so a file can always be read with one of the two keys.
+// content addressed chunks are encrypted with a key derived from the master key and can be shared between files, so they're always re-encrypted, and only deleted once every file has been rotated.
+// progress is recorded in journalDir. Without it a rotation that stopped between swapping a file's metadata and deleting its old chunks would leave them on the server forever, so if RotateMasterKey fails, call it again with the same keys, options and journalDir to pick up where it stopped.
+// the new key isn't stored anywhere, callers should save it (and StoreMasterKey it, if they use that) before calling this
+func RotateMasterKey(ctx context.Context, oldKey MasterKey, newKey MasterKey, journalDir string, opts ...RotateOption) error {
+	options := rotateOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	client := ClientFromContext(ctx).withContext(ctx)
+
+	journal, err := openRotationJournal(journalDir, oldKey, newKey, options.fullReKey)
+	if err != nil {
+		return err
+	}
+
+	err = rotateMasterKey(ctx, client, oldKey, newKey, journal, options)
+	if err != nil {
+		return errors.Join(err, journal.close())
+	}
+	return journal.remove()
+}
+
+func rotateMasterKey(ctx context.Context, client FileServerClient, oldKey MasterKey, newKey MasterKey, journal *rotationJournal, options rotateOptions) error {
+	encFileMetas, err := listEncryptedFileMetadata(client, []string{})
+	if err != nil {
+		return err
+	}
+	fileIDs := []string{}
+	for fileID := range encFileMetas {
+		fileIDs = append(fileIDs, fileID)
+	}
+	sort.Strings(fileIDs)
+
+	// old content addressed chunks, which can only be deleted once nothing refers to them
+	oldSharedChunks := map[string]struct{}{}
+	for _, fileID := range fileIDs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		fileMeta, err := decryptFileMetadata(encFileMetas[fileID], oldKey)
+		if err != nil {
+			// a previous attempt already swapped the metadata, but might not have gotten to clean up after it
+			newFileMeta, newErr := decryptFileMetadata(encFileMetas[fileID], newKey)
+			if newErr != nil {
+				return fmt.Errorf("file %s can't be decrypted with either key: %w", fileID, err)
+			}
+
+			oldChunkIDs := []string{}
+			for _, entry := range journal.rotatedChunks(fileID) {
+				oldChunkIDs = append(oldChunkIDs, entry.OldChunkID)
+			}
+			if journal.rotated[fileID] {
+				if newFileMeta.ContentAddressed {
+					for _, chunkID := range oldChunkIDs {
+						oldSharedChunks[chunkID] = struct{}{}
+					}
+				}
+				continue
+			}
+			err = finishFileRotation(client, fileID, oldChunkIDs, newFileMeta.ContentAddressed, oldSharedChunks, journal)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		err = rotateFile(ctx, client, fileMeta, oldKey, newKey, journal, oldSharedChunks, options.fullReKey)
+		if err != nil {
+			return fmt.Errorf("error rotating file %s: %w", fileID, err)
+		}
+	}
+
+	if len(oldSharedChunks) > 0 {
+		chunkIDs := []string{}
+		for chunkID := range oldSharedChunks {
+			chunkIDs = append(chunkIDs, chunkID)
+		}
+		sort.Strings(chunkIDs)
+		err = DeleteChunks(client, chunkIDs)
+		if err != nil {
+			return err
+		}
+	}
+
+	return rotateShareRevocations(client, oldKey, newKey)
+}
+
+// rotateFile moves fileMeta over to newKey. Files with a data key only need it rewrapped, unless they're content addressed or fullReKey is set.
+// otherwise a copy of every chunk is uploaded encrypted with newKey, then the metadata is swapped over to them
+func rotateFile(ctx context.Context, client FileServerClient, fileMeta *FileMetadata, oldKey MasterKey, newKey MasterKey, journal *rotationJournal, oldSharedChunks map[string]struct{}, fullReKey bool) error {
+	oldWrappedDataKey := fileMeta.WrappedDataKey
+	if len(fileMeta.WrappedDataKey) > 0 && !fullReKey {
+		dataKey, err := unwrapDataKey(fileMeta.WrappedDataKey, fileMeta.Id, oldKey)
+		if err != nil {
+			return err
+		}
+		fileMeta.WrappedDataKey, err = wrapDataKey(dataKey, fileMeta.Id, newKey)
+		if err != nil {
+			return err
+		}
+
+		if !fileMeta.ContentAddressed {
+			err = UpdateFileMetadata(client, fileMeta, newKey)
+			if err != nil {
+				return err
+			}
+			return finishFileRotation(client, fileMeta.Id, []string{}, false, oldSharedChunks, journal)
+		}
+	} else {
+		// files from before data keys existed get one now, so this is the last time their chunks need re-encrypting.
+		// a full re-key replaces every file's data key. An earlier attempt might have already encrypted some chunks with it
+		wrappedDataKey := journal.dataKey(fileMeta.Id)
+		if wrappedDataKey == nil {
+			var err error
+			_, wrappedDataKey, err = newDataKey(fileMeta.Id, newKey)
+			if err != nil {
+				return err
+			}
+			err = journal.record(&rotationEntry{
+				FileID:         fileMeta.Id,
+				WrappedDataKey: wrappedDataKey,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		fileMeta.WrappedDataKey = wrappedDataKey
+	}
+
+	oldCodec := newChunkCodec(oldKey)
+	chunkAEAD, err := newChunkCodec(newKey).chunkAEAD(fileMeta)
+	if err != nil {
+		return err
+	}
+
+	// chunks re-encrypted by an earlier attempt only count if the server actually has them
+	landedChunks := map[uint64]*rotationEntry{}
+	journalChunks := journal.rotatedChunks(fileMeta.Id)
+	if len(journalChunks) > 0 {
+		newChunkIDs := []string{}
+		for _, entry := range journalChunks {
+			newChunkIDs = append(newChunkIDs, entry.NewChunkID)
+		}
+		chunksUploaded, err := ChunksUploaded(client, newChunkIDs)
+		if err != nil {
+			return err
+		}
+		for indice, entry := range journalChunks {
+			if chunksUploaded[entry.NewChunkID] && fileMeta.Chunks[indice] == entry.OldChunkID {
+				landedChunks[indice] = entry
+			}
+		}
+	}
+
+	newChunks := sync.Map{}
+	// files from before FileMetadata.ChunkSizes existed get it filled in, since chunks bound to their file can't be decrypted without their size
+	chunkSizes := sync.Map{}
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(rotateConcurrency)
+	rotateClient := client.withContext(gctx)
+	for indice, oldChunkID := range fileMeta.Chunks {
+		if entry, ok := landedChunks[indice]; ok {
+			newChunks.Store(indice, entry.NewChunkID)
+			chunkSizes.Store(indice, entry.Size)
+			continue
+		}
+
+		g.Go(func() error {
+			chunkBin, err := oldCodec.downloadChunk(rotateClient, DownloadChunkArgs{
+				ChunkID:          oldChunkID,
+				FileID:           fileMeta.Id,
+				Indice:           indice,
+				Size:             fileMeta.ChunkSizes[indice],
+				ContentAddressed: fileMeta.ContentAddressed,
+				WrappedDataKey:   oldWrappedDataKey,
+			})
+			if err != nil {
+				return err
+			}
+
+			var newChunkID string
+			if fileMeta.ContentAddressed {
+				newChunkID = contentAddressedChunkID(newKey, chunkBin)
+				// another file being rotated might have already uploaded the same chunk
+				chunksUploaded, err := ChunksUploaded(rotateClient, []string{newChunkID})
+				if err != nil {
+					return err
+				}
+				if chunksUploaded[newChunkID] {
+					newChunks.Store(indice, newChunkID)
+					chunkSizes.Store(indice, uint32(len(chunkBin)))
+					return journal.record(&rotationEntry{
+						FileID:     fileMeta.Id,
+						Indice:     indice,
+						OldChunkID: oldChunkID,
+						NewChunkID: newChunkID,
+						Size:       uint32(len(chunkBin)),
+					})
+				}
+			} else {
+				chunkUUID, err := uuid.NewRandom()
+				if err != nil {
+					return err
+				}
+				newChunkID = chunkUUID.String()
+			}
+
+			chunkNonce := make([]byte, 24)
+			_, err = rand.Read(chunkNonce)
+			if err != nil {
+				return err
+			}
+			chunkHash := blake3.Sum256(chunkBin)
+			chunkMetadata := &ChunkMetadata{
+				Id:     newChunkID,
+				Hash:   chunkHash[:],
+				Size:   uint32(len(chunkBin)),
+				Indice: int64(indice),
+				Nonce:  chunkNonce,
+			}
+			if !fileMeta.ContentAddressed {
+				chunkMetadata.FormatVersion = chunkFormatBound
+			}
+
+			encChunk, err := compressEncryptChunk(chunkBin, chunkMetadata, fileMeta.Id, chunkAEAD, CompressionDefault, fileMeta.Padded)
+			if err != nil {
+				return err
+			}
+			var encChunkMetadata []byte
+			if fileMeta.ContentAddressed {
+				encChunkMetadata, err = compressEncryptContentAddressedChunkMetadata(chunkMetadata, chunkAEAD, fileMeta.Padded)
+			} else {
+				encChunkMetadata, err = compressEncryptChunkMetadata(chunkMetadata, chunkAEAD, fileMeta.Padded)
+			}
+			if err != nil {
+				return err
+			}
+
+			err = uploadChunkWithRetry(gctx, rotateClient, newChunkID, fileMeta.Id, fileMeta.ContentAddressed, encChunkMetadata, *encChunk)
+			if err != nil {
+				return err
+			}
+
+			newChunks.Store(indice, newChunkID)
+			chunkSizes.Store(indice, uint32(len(chunkBin)))
+			return journal.record(&rotationEntry{
+				FileID:     fileMeta.Id,
+				Indice:     indice,
+				OldChunkID: oldChunkID,
+				NewChunkID: newChunkID,
+				Size:       uint32(len(chunkBin)),
+			})
+		})
+	}
+	err = g.Wait()
+	if err != nil {
+		return err
+	}
+
+	if fileMeta.ChunkSizes == nil {
+		fileMeta.ChunkSizes = map[uint64]uint32{}
+	}
+	oldChunkIDs := []string{}
+	for indice, oldChunkID := range fileMeta.Chunks {
+		newChunkID, _ := newChunks.Load(indice)
+		chunkSize, _ := chunkSizes.Load(indice)
+		fileMeta.Chunks[indice] = newChunkID.(string)
+		fileMeta.ChunkSizes[indice] = chunkSize.(uint32)
+		oldChunkIDs = append(oldChunkIDs, oldChunkID)
+	}
+	err = UpdateFileMetadata(client, fileMeta, newKey)
+	if err != nil {
+		return err
+	}
+
+	return finishFileRotation(client, fileMeta.Id, oldChunkIDs, fileMeta.ContentAddressed, oldSharedChunks, journal)
+}
+
+// finishFileRotation cleans up after a file's metadata has been swapped to the new key
+func finishFileRotation(client FileServerClient, fileID string, oldChunkIDs []string, contentAddressed bool, oldSharedChunks map[string]struct{}, journal *rotationJournal) error {
+	if contentAddressed {
+		for _, chunkID := range oldChunkIDs {
+			oldSharedChunks[chunkID] = struct{}{}
+		}
+	} else if len(oldChunkIDs) > 0 {
+		err := DeleteChunks(client, oldChunkIDs)
+		if err != nil {
+			return err
+		}
+	}
+
+	return journal.record(&rotationEntry{
+		FileID:  fileID,
+		Rotated: true,
+	})
+}
@@ -0,0 +1,143 @@
+package bfsp_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/BillysBigFileServer/bfsp-go"
+)
+
+func TestRotateMasterKey(t *testing.T) {
+	ctx, srv, oldKey := newTestContext(t)
+	files := map[string][]byte{}
+	for name, opts := range map[string][]bfsp.UploadOption{
+		"fixed":           nil,
+		"content defined": {bfsp.WithContentDefinedChunking()},
+	} {
+		data := randomBytes(t, 2*bfsp.ChunkSize+3)
+		res, err := bfsp.UploadFile(ctx, &bfsp.FileInfo{Name: name, Reader: bytes.NewReader(data)}, 4, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[res.FileMetadata.Id] = data
+	}
+	chunkCount := srv.ChunkCount()
+
+	newKey := make(bfsp.MasterKey, 32)
+	copy(newKey, randomBytes(t, 32))
+	journalDir := t.TempDir()
+
+	// a rotation that stops early keeps its journal, which only resumes with the same keys
+	cancelledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	err := bfsp.RotateMasterKey(cancelledCtx, oldKey, newKey, journalDir)
+	if err == nil {
+		t.Fatal("expected a cancelled rotation to fail")
+	}
+	err = bfsp.RotateMasterKey(ctx, oldKey, randomBytes(t, 32), journalDir)
+	if !errors.Is(err, bfsp.ErrRotationKeyMismatch) {
+		t.Fatalf("got error %v resuming with another key, expected ErrRotationKeyMismatch", err)
+	}
+
+	err = bfsp.RotateMasterKey(ctx, oldKey, newKey, journalDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(journalDir); len(entries) != 0 {
+		t.Fatalf("the journal wasn't removed: %s", filepath.Join(journalDir, entries[0].Name()))
+	}
+	// old chunks are deleted as their replacements are uploaded
+	if srv.ChunkCount() > chunkCount {
+		t.Fatalf("%d chunks after rotating, %d before", srv.ChunkCount(), chunkCount)
+	}
+
+	newCtx := bfsp.ContextWithMasterKey(ctx, newKey)
+	for fileID, data := range files {
+		fileMeta, err := bfsp.DownloadFileMetadata(srv.Client(), fileID, newKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var downloaded bytes.Buffer
+		err = bfsp.DownloadFile(newCtx, fileMeta, &downloaded, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(downloaded.Bytes(), data) {
+			t.Fatalf("file %s changed when it was rotated", fileMeta.FileName)
+		}
+
+		_, err = bfsp.DownloadFileMetadata(srv.Client(), fileID, oldKey)
+		if err == nil {
+			t.Fatalf("file %s can still be read with the old key", fileMeta.FileName)
+		}
+	}
+}
+
+func TestRotateMasterKeyFullReKey(t *testing.T) {
+	ctx, srv, oldKey := newTestContext(t)
+	fileMeta, data := uploadTestFile(t, ctx, "file", 2*bfsp.ChunkSize+3)
+	// a share holds the file's data key, like someone who had the master key could have unwrapped it
+	view, err := bfsp.ShareFile(fileMeta, srv.Token(), oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunkCount := srv.ChunkCount()
+
+	// just rewrapping the data key leaves the file readable with it
+	rewrappedKey := make(bfsp.MasterKey, 32)
+	copy(rewrappedKey, randomBytes(t, 32))
+	err = bfsp.RotateMasterKey(ctx, oldKey, rewrappedKey, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSharedFile(t, srv, view, data)
+
+	newKey := make(bfsp.MasterKey, 32)
+	copy(newKey, randomBytes(t, 32))
+	journalDir := t.TempDir()
+	cancelledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	err = bfsp.RotateMasterKey(cancelledCtx, rewrappedKey, newKey, journalDir, bfsp.WithFullReKey())
+	if err == nil {
+		t.Fatal("expected a cancelled rotation to fail")
+	}
+	err = bfsp.RotateMasterKey(ctx, rewrappedKey, newKey, journalDir)
+	if !errors.Is(err, bfsp.ErrRotationModeMismatch) {
+		t.Fatalf("got error %v resuming a full re-key without WithFullReKey, expected ErrRotationModeMismatch", err)
+	}
+	err = bfsp.RotateMasterKey(ctx, rewrappedKey, newKey, journalDir, bfsp.WithFullReKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = bfsp.OpenSharedFile(srv.Client(), view)
+	if err == nil {
+		t.Fatal("opened a share with the data key from before the re-key")
+	}
+	rotatedFileMeta, err := bfsp.DownloadFileMetadata(srv.Client(), fileMeta.Id, newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for indice, chunkID := range rotatedFileMeta.Chunks {
+		if chunkID == fileMeta.Chunks[indice] {
+			t.Fatalf("chunk %d wasn't re-encrypted", indice)
+		}
+	}
+	// the old chunks are deleted once they're replaced
+	if srv.ChunkCount() != chunkCount {
+		t.Fatalf("%d chunks after re-keying, %d before", srv.ChunkCount(), chunkCount)
+	}
+
+	var downloaded bytes.Buffer
+	err = bfsp.DownloadFile(bfsp.ContextWithMasterKey(ctx, newKey), rotatedFileMeta, &downloaded, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(downloaded.Bytes(), data) {
+		t.Fatal("file changed when it was re-keyed")
+	}
+}
@@ -0,0 +1,199 @@
+package server
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/BillysBigFileServer/bfsp-go"
+	"github.com/biscuit-auth/biscuit-go/v2"
+	"github.com/biscuit-auth/biscuit-go/v2/datalog"
+)
+
+// the rights a request can need. Tokens attenuated by bfsp.ShareFile only grant some of them
+const (
+	rightRead      = "read"
+	rightWrite     = "write"
+	rightDelete    = "delete"
+	rightList      = "list"
+	rightUsage     = "usage"
+	rightMasterKey = "master_key"
+)
+
+// anyFile stands in for the files of a request that isn't about particular ones, like listing every file or a chunk query without a file id.
+// shares never allow it, so only tokens that aren't limited to some files can make such requests
+const anyFile = "*"
+
+// biscuit's default of 2ms is easily hit by a busy server
+const authorizeTimeout = 1 * time.Second
+
+// built by hand instead of with the parser, which is far slower than authorizing the token itself
+var (
+	// allow if user($user)
+	allowUserPolicy = biscuit.Policy{
+		Kind: biscuit.PolicyKindAllow,
+		Queries: []biscuit.Rule{{
+			Head: biscuit.Predicate{Name: "allow"},
+			Body: []biscuit.Predicate{{Name: "user", IDs: []biscuit.Term{biscuit.Variable("user")}}},
+		}},
+	}
+	// user_id($user) <- user($user)
+	userIDRule = biscuit.Rule{
+		Head: biscuit.Predicate{Name: "user_id", IDs: []biscuit.Term{biscuit.Variable("user")}},
+		Body: []biscuit.Predicate{{Name: "user", IDs: []biscuit.Term{biscuit.Variable("user")}}},
+	}
+)
+
+var ErrUnauthorized = errors.New("unauthorized")
+
+// authorize verifies a token against the root key, checks that it grants right over every file in fileIDs, and returns the id of the user it belongs to.
+// no fileIDs means the request isn't about particular files, which needs a token that isn't limited to some
+func (s *Server) authorize(tokenStr string, right string, fileIDs []string) (int64, error) {
+	if len(fileIDs) == 0 {
+		fileIDs = []string{anyFile}
+	}
+
+	tokenBytes, err := base64.URLEncoding.DecodeString(tokenStr)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrUnauthorized, err)
+	}
+
+	token, err := biscuit.Unmarshal(tokenBytes)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrUnauthorized, err)
+	}
+
+	authorizer, err := token.Authorizer(s.rootKey, biscuit.WithWorldOptions(datalog.WithMaxDuration(authorizeTimeout)))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrUnauthorized, err)
+	}
+
+	fileIDSet := biscuit.Set{}
+	for _, fileID := range fileIDs {
+		fileIDSet = append(fileIDSet, biscuit.String(fileID))
+	}
+	authorizer.AddFact(biscuit.Fact{
+		Predicate: biscuit.Predicate{
+			Name: "right",
+			IDs:  []biscuit.Term{biscuit.String(right)},
+		},
+	})
+	authorizer.AddFact(biscuit.Fact{
+		Predicate: biscuit.Predicate{
+			Name: "file_ids",
+			IDs:  []biscuit.Term{fileIDSet},
+		},
+	})
+	// for shares that expire
+	authorizer.AddFact(biscuit.Fact{
+		Predicate: biscuit.Predicate{
+			Name: "time",
+			IDs:  []biscuit.Term{biscuit.Date(time.Now())},
+		},
+	})
+
+	authorizer.AddPolicy(allowUserPolicy)
+
+	err = authorizer.Authorize()
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrUnauthorized, err)
+	}
+
+	userFacts, err := authorizer.Query(userIDRule)
+	if err != nil {
+		return 0, err
+	}
+	if len(userFacts) != 1 {
+		return 0, fmt.Errorf("%w: token must contain exactly one user", ErrUnauthorized)
+	}
+	userID, ok := userFacts[0].IDs[0].(biscuit.Integer)
+	if !ok {
+		return 0, fmt.Errorf("%w: user id must be an integer", ErrUnauthorized)
+	}
+
+	err = s.checkShareRevocations(token, int64(userID))
+	if err != nil {
+		return 0, err
+	}
+
+	return int64(userID), nil
+}
+
+// chunkFileIDs returns the file ids to authorize a chunk request for fileID with, none if it didn't say
+func chunkFileIDs(fileID string) []string {
+	if fileID == "" {
+		return nil
+	}
+	return []string{fileID}
+}
+
+// authorizeChunkFile checks that a token already authorized for requestFileID can touch chunk, which is part of another file unless the ids match.
+// content addressed chunks are recorded with the first file they were uploaded for, and chunks from older clients with none
+func (s *Server) authorizeChunkFile(tokenStr string, right string, requestFileID string, chunk *bfsp.DownloadChunkResp_ChunkData) error {
+	if chunk.FileId == requestFileID {
+		return nil
+	}
+	_, err := s.authorize(tokenStr, right, chunkFileIDs(chunk.FileId))
+	return err
+}
+
+// checkShareRevocations rejects share tokens with a share_id fact the user has revoked, going by the list the client stores as file metadata with bfsp.ShareRevocationsID.
+// tokens without attenuation blocks aren't shares, so they never touch the revocation list
+func (s *Server) checkShareRevocations(token *biscuit.Biscuit, userID int64) error {
+	if token.BlockCount() == 0 {
+		return nil
+	}
+
+	encRevocations, err := s.storage.GetFileMetadata(userID, bfsp.ShareRevocationsID)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	revocations, err := bfsp.ParseShareRevocationList(encRevocations)
+	if err != nil {
+		return err
+	}
+	// block facts aren't visible to authorizer queries, so look for each revoked id in the token itself
+	for _, shareID := range revocations.RevokedShareIds {
+		_, err := token.GetBlockID(biscuit.Fact{
+			Predicate: biscuit.Predicate{
+				Name: "share_id",
+				IDs:  []biscuit.Term{biscuit.String(shareID)},
+			},
+		})
+		if err == nil {
+			return fmt.Errorf("%w: share %s has been revoked", ErrUnauthorized, shareID)
+		}
+	}
+	return nil
+}
+
+// NewToken creates a token for userID signed by rootKey, in the same form big-central hands out
+func NewToken(rootKey ed25519.PrivateKey, userID int64) (string, error) {
+	builder := biscuit.NewBuilder(rootKey, biscuit.WithRandom(rand.Reader))
+	err := builder.AddAuthorityFact(biscuit.Fact{
+		Predicate: biscuit.Predicate{
+			Name: "user",
+			IDs:  []biscuit.Term{biscuit.Integer(userID)},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	token, err := builder.Build()
+	if err != nil {
+		return "", err
+	}
+
+	serializedToken, err := token.Serialize()
+	if err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(serializedToken), nil
+}
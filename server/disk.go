@@ -0,0 +1,354 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/BillysBigFileServer/bfsp-go"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
+)
+
+const chunkMetaSuffix = ".meta"
+
+// DiskStorage stores each user's chunks and metadata as files under a root directory:
+//
+//	<root>/<user id>/chunks/<chunk id>       encrypted chunk bytes
+//	<root>/<user id>/chunks/<chunk id>.meta  chunk metadata
+//	<root>/<user id>/files/<file id>         encrypted file metadata
+//	<root>/<user id>/master_key              encrypted master key
+type DiskStorage struct {
+	root string
+
+	mu    sync.Mutex
+	users map[int64]*diskUser
+}
+
+// diskUser tracks a user's usage, so it's only worked out from the chunks on disk once per process
+type diskUser struct {
+	// held while a chunk is written or deleted, so the usage stays in step with the chunks
+	mu          sync.Mutex
+	usageLoaded bool
+	usage       uint64
+}
+
+func NewDiskStorage(root string) (*DiskStorage, error) {
+	err := os.MkdirAll(root, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DiskStorage{
+		root:  root,
+		users: map[int64]*diskUser{},
+	}, nil
+}
+
+func (s *DiskStorage) userDir(userID int64, subdir string) string {
+	return filepath.Join(s.root, strconv.FormatInt(userID, 10), subdir)
+}
+
+// idPath returns the path of an object named by a UUID, so that ids can never escape the user's directory
+func (s *DiskStorage) idPath(userID int64, subdir string, id string) (string, error) {
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return "", fmt.Errorf("invalid id %q: %w", id, err)
+	}
+	return filepath.Join(s.userDir(userID, subdir), parsedID.String()), nil
+}
+
+// writeFileAtomic writes to a temporary file and renames it into place, so readers never see partial writes
+func writeFileAtomic(path string, data []byte) error {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	_, err = tmpFile.Write(data)
+	if err != nil {
+		tmpFile.Close()
+		return err
+	}
+	err = tmpFile.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFile.Name(), path)
+}
+
+func readFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func removeFile(path string) error {
+	err := os.Remove(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// listIDs lists the objects in one of a user's directories, skipping chunk metadata and temporary files
+func (s *DiskStorage) listIDs(userID int64, subdir string) ([]string, error) {
+	entries, err := os.ReadDir(s.userDir(userID, subdir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || strings.HasSuffix(name, chunkMetaSuffix) {
+			continue
+		}
+		ids = append(ids, name)
+	}
+	return ids, nil
+}
+
+// lockUser returns userID's diskUser locked, with its usage loaded
+func (s *DiskStorage) lockUser(userID int64) (*diskUser, error) {
+	s.mu.Lock()
+	user, ok := s.users[userID]
+	if !ok {
+		user = &diskUser{}
+		s.users[userID] = user
+	}
+	s.mu.Unlock()
+
+	user.mu.Lock()
+	if !user.usageLoaded {
+		usage, err := s.diskUsage(userID)
+		if err != nil {
+			user.mu.Unlock()
+			return nil, err
+		}
+		user.usage = usage
+		user.usageLoaded = true
+	}
+	return user, nil
+}
+
+// chunkSize returns the size of a chunk on disk, which is 0 if it doesn't exist
+func chunkSize(chunkPath string) (uint64, error) {
+	info, err := os.Stat(chunkPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return uint64(info.Size()), nil
+}
+
+func (s *DiskStorage) PutChunk(userID int64, chunkID string, chunk *bfsp.DownloadChunkResp_ChunkData) error {
+	chunkPath, err := s.idPath(userID, "chunks", chunkID)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.lockUser(userID)
+	if err != nil {
+		return err
+	}
+	defer user.mu.Unlock()
+	oldSize, err := chunkSize(chunkPath)
+	if err != nil {
+		return err
+	}
+
+	chunkMeta := &bfsp.DownloadChunkResp_ChunkData{
+		ChunkMetadata:    chunk.ChunkMetadata,
+		EncChunkMetadata: chunk.EncChunkMetadata,
+		FileId:           chunk.FileId,
+	}
+	chunkMetaBin, err := proto.Marshal(chunkMeta)
+	if err != nil {
+		return err
+	}
+
+	// write the metadata first, so a chunk is never visible without it
+	err = writeFileAtomic(chunkPath+chunkMetaSuffix, chunkMetaBin)
+	if err != nil {
+		return err
+	}
+	err = writeFileAtomic(chunkPath, chunk.Chunk)
+	if err != nil {
+		return err
+	}
+
+	user.usage = user.usage - oldSize + uint64(len(chunk.Chunk))
+	return nil
+}
+
+func (s *DiskStorage) GetChunk(userID int64, chunkID string) (*bfsp.DownloadChunkResp_ChunkData, error) {
+	chunkPath, err := s.idPath(userID, "chunks", chunkID)
+	if err != nil {
+		return nil, err
+	}
+
+	chunkBin, err := readFile(chunkPath)
+	if err != nil {
+		return nil, err
+	}
+	chunkMetaBin, err := readFile(chunkPath + chunkMetaSuffix)
+	if err != nil {
+		return nil, err
+	}
+
+	var chunk bfsp.DownloadChunkResp_ChunkData
+	err = proto.Unmarshal(chunkMetaBin, &chunk)
+	if err != nil {
+		return nil, err
+	}
+	chunk.Chunk = chunkBin
+
+	return &chunk, nil
+}
+
+func (s *DiskStorage) HasChunk(userID int64, chunkID string) (bool, error) {
+	chunkPath, err := s.idPath(userID, "chunks", chunkID)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = os.Stat(chunkPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *DiskStorage) ListChunkIDs(userID int64) ([]string, error) {
+	return s.listIDs(userID, "chunks")
+}
+
+func (s *DiskStorage) DeleteChunk(userID int64, chunkID string) error {
+	chunkPath, err := s.idPath(userID, "chunks", chunkID)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.lockUser(userID)
+	if err != nil {
+		return err
+	}
+	defer user.mu.Unlock()
+	size, err := chunkSize(chunkPath)
+	if err != nil {
+		return err
+	}
+
+	err = removeFile(chunkPath)
+	if err != nil {
+		return err
+	}
+	user.usage -= size
+	return removeFile(chunkPath + chunkMetaSuffix)
+}
+
+func (s *DiskStorage) PutFileMetadata(userID int64, meta *bfsp.EncryptedFileMetadata) error {
+	metaPath, err := s.idPath(userID, "files", meta.Id)
+	if err != nil {
+		return err
+	}
+
+	metaBin, err := proto.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(metaPath, metaBin)
+}
+
+func (s *DiskStorage) GetFileMetadata(userID int64, fileID string) (*bfsp.EncryptedFileMetadata, error) {
+	metaPath, err := s.idPath(userID, "files", fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	metaBin, err := readFile(metaPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var meta bfsp.EncryptedFileMetadata
+	err = proto.Unmarshal(metaBin, &meta)
+	if err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+func (s *DiskStorage) ListFileMetadataIDs(userID int64) ([]string, error) {
+	return s.listIDs(userID, "files")
+}
+
+func (s *DiskStorage) DeleteFileMetadata(userID int64, fileID string) error {
+	metaPath, err := s.idPath(userID, "files", fileID)
+	if err != nil {
+		return err
+	}
+	return removeFile(metaPath)
+}
+
+func (s *DiskStorage) PutMasterKey(userID int64, encryptedKey []byte) error {
+	return writeFileAtomic(filepath.Join(s.userDir(userID, ""), "master_key"), encryptedKey)
+}
+
+func (s *DiskStorage) GetMasterKey(userID int64) ([]byte, error) {
+	return readFile(filepath.Join(s.userDir(userID, ""), "master_key"))
+}
+
+func (s *DiskStorage) Usage(userID int64) (uint64, error) {
+	user, err := s.lockUser(userID)
+	if err != nil {
+		return 0, err
+	}
+	defer user.mu.Unlock()
+	return user.usage, nil
+}
+
+// diskUsage adds up the size of every chunk userID has on disk
+func (s *DiskStorage) diskUsage(userID int64) (uint64, error) {
+	chunkIDs, err := s.ListChunkIDs(userID)
+	if err != nil {
+		return 0, err
+	}
+
+	var usage uint64 = 0
+	for _, chunkID := range chunkIDs {
+		info, err := os.Stat(filepath.Join(s.userDir(userID, "chunks"), chunkID))
+		if errors.Is(err, fs.ErrNotExist) {
+			// deleted since we listed the directory
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		usage += uint64(info.Size())
+	}
+	return usage, nil
+}
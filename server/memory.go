@@ -0,0 +1,159 @@
+package server
+
+import (
+	"sync"
+
+	"github.com/BillysBigFileServer/bfsp-go"
+	"google.golang.org/protobuf/proto"
+)
+
+type memoryUser struct {
+	chunks    map[string]*bfsp.DownloadChunkResp_ChunkData
+	fileMetas map[string]*bfsp.EncryptedFileMetadata
+	masterKey []byte
+	usage     uint64
+}
+
+// MemoryStorage keeps everything in memory, and is mostly useful for tests
+type MemoryStorage struct {
+	mu    sync.Mutex
+	users map[int64]*memoryUser
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		users: map[int64]*memoryUser{},
+	}
+}
+
+func (s *MemoryStorage) user(userID int64) *memoryUser {
+	user, ok := s.users[userID]
+	if !ok {
+		user = &memoryUser{
+			chunks:    map[string]*bfsp.DownloadChunkResp_ChunkData{},
+			fileMetas: map[string]*bfsp.EncryptedFileMetadata{},
+		}
+		s.users[userID] = user
+	}
+	return user
+}
+
+func (s *MemoryStorage) PutChunk(userID int64, chunkID string, chunk *bfsp.DownloadChunkResp_ChunkData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user := s.user(userID)
+	if old, ok := user.chunks[chunkID]; ok {
+		user.usage -= uint64(len(old.Chunk))
+	}
+	user.chunks[chunkID] = proto.Clone(chunk).(*bfsp.DownloadChunkResp_ChunkData)
+	user.usage += uint64(len(chunk.Chunk))
+
+	return nil
+}
+
+func (s *MemoryStorage) GetChunk(userID int64, chunkID string) (*bfsp.DownloadChunkResp_ChunkData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chunk, ok := s.user(userID).chunks[chunkID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return proto.Clone(chunk).(*bfsp.DownloadChunkResp_ChunkData), nil
+}
+
+func (s *MemoryStorage) HasChunk(userID int64, chunkID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.user(userID).chunks[chunkID]
+	return ok, nil
+}
+
+func (s *MemoryStorage) ListChunkIDs(userID int64) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chunkIDs := []string{}
+	for chunkID := range s.user(userID).chunks {
+		chunkIDs = append(chunkIDs, chunkID)
+	}
+	return chunkIDs, nil
+}
+
+func (s *MemoryStorage) DeleteChunk(userID int64, chunkID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user := s.user(userID)
+	if chunk, ok := user.chunks[chunkID]; ok {
+		user.usage -= uint64(len(chunk.Chunk))
+		delete(user.chunks, chunkID)
+	}
+	return nil
+}
+
+func (s *MemoryStorage) PutFileMetadata(userID int64, meta *bfsp.EncryptedFileMetadata) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.user(userID).fileMetas[meta.Id] = proto.Clone(meta).(*bfsp.EncryptedFileMetadata)
+	return nil
+}
+
+func (s *MemoryStorage) GetFileMetadata(userID int64, fileID string) (*bfsp.EncryptedFileMetadata, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	meta, ok := s.user(userID).fileMetas[fileID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return proto.Clone(meta).(*bfsp.EncryptedFileMetadata), nil
+}
+
+func (s *MemoryStorage) ListFileMetadataIDs(userID int64) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fileIDs := []string{}
+	for fileID := range s.user(userID).fileMetas {
+		fileIDs = append(fileIDs, fileID)
+	}
+	return fileIDs, nil
+}
+
+func (s *MemoryStorage) DeleteFileMetadata(userID int64, fileID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.user(userID).fileMetas, fileID)
+	return nil
+}
+
+func (s *MemoryStorage) PutMasterKey(userID int64, encryptedKey []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.user(userID).masterKey = append([]byte{}, encryptedKey...)
+	return nil
+}
+
+func (s *MemoryStorage) GetMasterKey(userID int64) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	masterKey := s.user(userID).masterKey
+	if masterKey == nil {
+		return nil, ErrNotFound
+	}
+	return append([]byte{}, masterKey...), nil
+}
+
+func (s *MemoryStorage) Usage(userID int64) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.user(userID).usage, nil
+}
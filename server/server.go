@@ -0,0 +1,547 @@
+// Package server is a self-hostable file server speaking the same protocol as the hosted one
+package server
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/BillysBigFileServer/bfsp-go"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
+)
+
+const DefaultStorageCap = 5 * 1024 * 1024 * 1024
+
+// Server handles FileServerMessages for any user holding a token signed by rootKey
+type Server struct {
+	StorageCap uint64
+
+	storage Storage
+	rootKey ed25519.PublicKey
+	// a *sync.Mutex per user, held while checking their storage cap and writing a chunk so concurrent uploads can't overshoot it
+	uploadLocks sync.Map
+}
+
+func New(storage Storage, rootKey ed25519.PublicKey) *Server {
+	return &Server{
+		StorageCap: DefaultStorageCap,
+		storage:    storage,
+		rootKey:    rootKey,
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/api" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	msg, err := bfsp.ReadFileServerMessage(http.MaxBytesReader(w, r.Body, 4+bfsp.MaxFileServerMessageSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp, err := s.HandleFileServerMessage(msg)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	respBin, err := bfsp.EncodeFileServerResponse(resp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(respBin)
+}
+
+// HandleFileServerMessage handles a single message. Failures are reported inside the response, the same way the hosted server does; an error is only returned for messages it doesn't understand
+func (s *Server) HandleFileServerMessage(msg *bfsp.FileServerMessage) (proto.Message, error) {
+	token := msg.GetAuth().GetToken()
+
+	var resp proto.Message
+	var err error
+	switch m := msg.Message.(type) {
+	case *bfsp.FileServerMessage_UploadChunk_:
+		resp, err = s.uploadChunk(token, m.UploadChunk)
+	case *bfsp.FileServerMessage_ChunksUploadedQuery_:
+		resp, err = s.chunksUploaded(token, m.ChunksUploadedQuery)
+	case *bfsp.FileServerMessage_DownloadChunkQuery_:
+		resp, err = s.downloadChunk(token, m.DownloadChunkQuery)
+	case *bfsp.FileServerMessage_DeleteChunksQuery_:
+		resp, err = s.deleteChunks(token, m.DeleteChunksQuery)
+	case *bfsp.FileServerMessage_UploadFileMetadata_:
+		resp, err = s.uploadFileMetadata(token, m.UploadFileMetadata)
+	case *bfsp.FileServerMessage_UpdateFileMetadata_:
+		resp, err = s.updateFileMetadata(token, m.UpdateFileMetadata)
+	case *bfsp.FileServerMessage_DownloadFileMetadataQuery_:
+		resp, err = s.downloadFileMetadata(token, m.DownloadFileMetadataQuery)
+	case *bfsp.FileServerMessage_ListFileMetadataQuery_:
+		resp, err = s.listFileMetadata(token, m.ListFileMetadataQuery)
+	case *bfsp.FileServerMessage_ListChunkMetadataQuery_:
+		resp, err = s.listChunkMetadata(token, m.ListChunkMetadataQuery)
+	case *bfsp.FileServerMessage_DeleteFileMetadataQuery_:
+		resp, err = s.deleteFileMetadata(token, m.DeleteFileMetadataQuery)
+	case *bfsp.FileServerMessage_GetUsageQuery_:
+		resp, err = s.getUsage(token)
+	case *bfsp.FileServerMessage_SetMasterKey:
+		resp, err = s.setMasterKey(token, m.SetMasterKey)
+	case *bfsp.FileServerMessage_GetMasterKey:
+		resp, err = s.getMasterKey(token)
+	default:
+		return nil, fmt.Errorf("unhandled FileServerMessage type %T", msg.Message)
+	}
+
+	if err != nil {
+		return errorResp(msg, err), nil
+	}
+	return resp, nil
+}
+
+// errorResp builds the response type for msg with its err field set
+func errorResp(msg *bfsp.FileServerMessage, err error) proto.Message {
+	errStr := err.Error()
+
+	switch msg.Message.(type) {
+	case *bfsp.FileServerMessage_UploadChunk_:
+		return &bfsp.UploadChunkResp{Err: &errStr}
+	case *bfsp.FileServerMessage_ChunksUploadedQuery_:
+		return &bfsp.ChunksUploadedQueryResp{Response: &bfsp.ChunksUploadedQueryResp_Err{Err: errStr}}
+	case *bfsp.FileServerMessage_DownloadChunkQuery_:
+		return &bfsp.DownloadChunkResp{Response: &bfsp.DownloadChunkResp_Err{Err: errStr}}
+	case *bfsp.FileServerMessage_DeleteChunksQuery_:
+		return &bfsp.DeleteChunksResp{Err: &errStr}
+	case *bfsp.FileServerMessage_UploadFileMetadata_:
+		return &bfsp.UploadFileMetadataResp{Err: &errStr}
+	case *bfsp.FileServerMessage_UpdateFileMetadata_:
+		return &bfsp.UpdateFileMetadataResp{Err: &errStr}
+	case *bfsp.FileServerMessage_DownloadFileMetadataQuery_:
+		return &bfsp.DownloadFileMetadataResp{Response: &bfsp.DownloadFileMetadataResp_Err{Err: errStr}}
+	case *bfsp.FileServerMessage_ListFileMetadataQuery_:
+		return &bfsp.ListFileMetadataResp{Response: &bfsp.ListFileMetadataResp_Err{Err: errStr}}
+	case *bfsp.FileServerMessage_ListChunkMetadataQuery_:
+		return &bfsp.ListChunkMetadataResp{Response: &bfsp.ListChunkMetadataResp_Err{Err: errStr}}
+	case *bfsp.FileServerMessage_DeleteFileMetadataQuery_:
+		return &bfsp.DeleteFileMetadataResp{Err: &errStr}
+	case *bfsp.FileServerMessage_GetUsageQuery_:
+		return &bfsp.GetUsageResp{Response: &bfsp.GetUsageResp_Err{Err: errStr}}
+	case *bfsp.FileServerMessage_SetMasterKey:
+		return &bfsp.SetMasterEncryptionKeyResp{Err: &errStr}
+	case *bfsp.FileServerMessage_GetMasterKey:
+		return &bfsp.GetMasterEncryptionKeyResp{Response: &bfsp.GetMasterEncryptionKeyResp_Err{Err: errStr}}
+	default:
+		return nil
+	}
+}
+
+func validateID(id string) error {
+	_, err := uuid.Parse(id)
+	if err != nil {
+		return fmt.Errorf("invalid id %q: %w", id, err)
+	}
+	return nil
+}
+
+func (s *Server) uploadChunk(token string, msg *bfsp.FileServerMessage_UploadChunk) (proto.Message, error) {
+	if msg.FileId != "" {
+		if err := validateID(msg.FileId); err != nil {
+			return nil, err
+		}
+	}
+	userID, err := s.authorize(token, rightWrite, chunkFileIDs(msg.FileId))
+	if err != nil {
+		return nil, err
+	}
+
+	var chunkID string
+	switch {
+	case msg.EncChunkMetadata != nil:
+		chunkID = msg.EncChunkMetadata.Id
+	case msg.ChunkMetadata != nil:
+		chunkID = msg.ChunkMetadata.Id
+	default:
+		return nil, errors.New("missing chunk metadata")
+	}
+	if err := validateID(chunkID); err != nil {
+		return nil, err
+	}
+
+	uploadLock, _ := s.uploadLocks.LoadOrStore(userID, &sync.Mutex{})
+	uploadLock.(*sync.Mutex).Lock()
+	defer uploadLock.(*sync.Mutex).Unlock()
+
+	// chunks can't be replaced, or a token that can only write, like a drop box share, could overwrite the owner's chunks.
+	// the same chunk sent again, because the response to it was lost, doesn't replace anything
+	existingChunk, err := s.storage.GetChunk(userID, chunkID)
+	if err == nil {
+		if existingChunk.FileId == msg.FileId && bytes.Equal(existingChunk.Chunk, msg.Chunk) && proto.Equal(existingChunk.EncChunkMetadata, msg.EncChunkMetadata) && proto.Equal(existingChunk.ChunkMetadata, msg.ChunkMetadata) {
+			return &bfsp.UploadChunkResp{}, nil
+		}
+		return nil, fmt.Errorf("chunk %s already exists", chunkID)
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	usage, err := s.storage.Usage(userID)
+	if err != nil {
+		return nil, err
+	}
+	if usage+uint64(len(msg.Chunk)) > s.StorageCap {
+		return nil, fmt.Errorf("storage cap of %d bytes exceeded", s.StorageCap)
+	}
+
+	err = s.storage.PutChunk(userID, chunkID, &bfsp.DownloadChunkResp_ChunkData{
+		ChunkMetadata:    msg.ChunkMetadata,
+		EncChunkMetadata: msg.EncChunkMetadata,
+		Chunk:            msg.Chunk,
+		FileId:           msg.FileId,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &bfsp.UploadChunkResp{}, nil
+}
+
+// chunksUploaded isn't about particular files, so tokens limited to some files can't ask it
+func (s *Server) chunksUploaded(token string, msg *bfsp.FileServerMessage_ChunksUploadedQuery) (proto.Message, error) {
+	userID, err := s.authorize(token, rightRead, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	chunksUploaded := []*bfsp.ChunksUploadedQueryResp_ChunkUploaded{}
+	for _, chunkIDStr := range msg.ChunkIds {
+		chunkID, err := uuid.Parse(chunkIDStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid id %q: %w", chunkIDStr, err)
+		}
+		uploaded, err := s.storage.HasChunk(userID, chunkIDStr)
+		if err != nil {
+			return nil, err
+		}
+
+		chunksUploaded = append(chunksUploaded, &bfsp.ChunksUploadedQueryResp_ChunkUploaded{
+			ChunkId:  chunkID[:],
+			Uploaded: uploaded,
+		})
+	}
+
+	return &bfsp.ChunksUploadedQueryResp{
+		Response: &bfsp.ChunksUploadedQueryResp_Chunks{
+			Chunks: &bfsp.ChunksUploadedQueryResp_ChunksUploaded{Chunks: chunksUploaded},
+		},
+	}, nil
+}
+
+func (s *Server) downloadChunk(token string, msg *bfsp.FileServerMessage_DownloadChunkQuery) (proto.Message, error) {
+	if err := validateID(msg.ChunkId); err != nil {
+		return nil, err
+	}
+	if msg.FileId != "" {
+		if err := validateID(msg.FileId); err != nil {
+			return nil, err
+		}
+	}
+	userID, err := s.authorize(token, rightRead, chunkFileIDs(msg.FileId))
+	if err != nil {
+		return nil, err
+	}
+
+	chunk, err := s.storage.GetChunk(userID, msg.ChunkId)
+	if errors.Is(err, ErrNotFound) {
+		return nil, errors.New("chunk not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = s.authorizeChunkFile(token, rightRead, msg.FileId, chunk)
+	if err != nil {
+		return nil, err
+	}
+
+	return &bfsp.DownloadChunkResp{
+		Response: &bfsp.DownloadChunkResp_ChunkData_{ChunkData: chunk},
+	}, nil
+}
+
+func (s *Server) deleteChunks(token string, msg *bfsp.FileServerMessage_DeleteChunksQuery) (proto.Message, error) {
+	userID, err := s.authorize(token, rightDelete, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, chunkID := range msg.ChunkIds {
+		if err := validateID(chunkID); err != nil {
+			return nil, err
+		}
+	}
+	for _, chunkID := range msg.ChunkIds {
+		err := s.storage.DeleteChunk(userID, chunkID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &bfsp.DeleteChunksResp{}, nil
+}
+
+func (s *Server) uploadFileMetadata(token string, msg *bfsp.FileServerMessage_UploadFileMetadata) (proto.Message, error) {
+	meta := msg.EncryptedFileMetadata
+	if meta == nil {
+		return nil, errors.New("missing file metadata")
+	}
+	if err := validateID(meta.Id); err != nil {
+		return nil, err
+	}
+
+	userID, err := s.authorize(token, rightWrite, []string{meta.Id})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.storage.GetFileMetadata(userID, meta.Id)
+	if err == nil {
+		return nil, fmt.Errorf("file metadata %s already exists", meta.Id)
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	err = s.storage.PutFileMetadata(userID, meta)
+	if err != nil {
+		return nil, err
+	}
+
+	return &bfsp.UploadFileMetadataResp{}, nil
+}
+
+func (s *Server) updateFileMetadata(token string, msg *bfsp.FileServerMessage_UpdateFileMetadata) (proto.Message, error) {
+	meta := msg.EncryptedFileMetadata
+	if meta == nil {
+		return nil, errors.New("missing file metadata")
+	}
+	if err := validateID(meta.Id); err != nil {
+		return nil, err
+	}
+
+	userID, err := s.authorize(token, rightWrite, []string{meta.Id})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.storage.GetFileMetadata(userID, meta.Id)
+	if errors.Is(err, ErrNotFound) {
+		return nil, errors.New("file metadata not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.storage.PutFileMetadata(userID, meta)
+	if err != nil {
+		return nil, err
+	}
+
+	return &bfsp.UpdateFileMetadataResp{}, nil
+}
+
+func (s *Server) downloadFileMetadata(token string, msg *bfsp.FileServerMessage_DownloadFileMetadataQuery) (proto.Message, error) {
+	if err := validateID(msg.Id); err != nil {
+		return nil, err
+	}
+
+	userID, err := s.authorize(token, rightRead, []string{msg.Id})
+	if err != nil {
+		return nil, err
+	}
+
+	meta, err := s.storage.GetFileMetadata(userID, msg.Id)
+	if errors.Is(err, ErrNotFound) {
+		return nil, errors.New("file metadata not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &bfsp.DownloadFileMetadataResp{
+		Response: &bfsp.DownloadFileMetadataResp_EncryptedFileMetadata{EncryptedFileMetadata: meta},
+	}, nil
+}
+
+func (s *Server) listFileMetadata(token string, msg *bfsp.FileServerMessage_ListFileMetadataQuery) (proto.Message, error) {
+	for _, fileID := range msg.Ids {
+		if err := validateID(fileID); err != nil {
+			return nil, err
+		}
+	}
+
+	// listing every file needs more than read access to some of them
+	right := rightRead
+	if len(msg.Ids) == 0 {
+		right = rightList
+	}
+	userID, err := s.authorize(token, right, msg.Ids)
+	if err != nil {
+		return nil, err
+	}
+
+	fileIDs := msg.Ids
+	if len(fileIDs) == 0 {
+		fileIDs, err = s.storage.ListFileMetadataIDs(userID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	metas := map[string]*bfsp.EncryptedFileMetadata{}
+	for _, fileID := range fileIDs {
+		meta, err := s.storage.GetFileMetadata(userID, fileID)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		metas[fileID] = meta
+	}
+
+	return &bfsp.ListFileMetadataResp{
+		Response: &bfsp.ListFileMetadataResp_Metadatas{
+			Metadatas: &bfsp.ListFileMetadataResp_FileMetadatas{Metadatas: metas},
+		},
+	}, nil
+}
+
+func (s *Server) listChunkMetadata(token string, msg *bfsp.FileServerMessage_ListChunkMetadataQuery) (proto.Message, error) {
+	for _, chunkID := range msg.Ids {
+		if err := validateID(chunkID); err != nil {
+			return nil, err
+		}
+	}
+
+	right := rightRead
+	if len(msg.Ids) == 0 {
+		right = rightList
+	}
+	userID, err := s.authorize(token, right, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	chunkIDs := msg.Ids
+	if len(chunkIDs) == 0 {
+		chunkIDs, err = s.storage.ListChunkIDs(userID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	metas := map[string]*bfsp.ChunkMetadata{}
+	for _, chunkID := range chunkIDs {
+		chunk, err := s.storage.GetChunk(userID, chunkID)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// encrypted chunk metadata can't be read here, so all we know is the id and stored size
+		meta := chunk.ChunkMetadata
+		if meta == nil {
+			meta = &bfsp.ChunkMetadata{Id: chunkID, Size: uint32(len(chunk.Chunk))}
+		}
+		metas[chunkID] = meta
+	}
+
+	return &bfsp.ListChunkMetadataResp{
+		Response: &bfsp.ListChunkMetadataResp_Metadatas{
+			Metadatas: &bfsp.ListChunkMetadataResp_ChunkMetadatas{Metadatas: metas},
+		},
+	}, nil
+}
+
+func (s *Server) deleteFileMetadata(token string, msg *bfsp.FileServerMessage_DeleteFileMetadataQuery) (proto.Message, error) {
+	if err := validateID(msg.Id); err != nil {
+		return nil, err
+	}
+
+	userID, err := s.authorize(token, rightDelete, []string{msg.Id})
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.storage.DeleteFileMetadata(userID, msg.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &bfsp.DeleteFileMetadataResp{}, nil
+}
+
+func (s *Server) getUsage(token string) (proto.Message, error) {
+	userID, err := s.authorize(token, rightUsage, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	usage, err := s.storage.Usage(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &bfsp.GetUsageResp{
+		Response: &bfsp.GetUsageResp_Usage_{
+			Usage: &bfsp.GetUsageResp_Usage{
+				TotalUsage: usage,
+				StorageCap: s.StorageCap,
+			},
+		},
+	}, nil
+}
+
+func (s *Server) setMasterKey(token string, msg *bfsp.FileServerMessage_SetMasterEncryptionKey) (proto.Message, error) {
+	userID, err := s.authorize(token, rightMasterKey, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.storage.PutMasterKey(userID, msg.EncryptedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &bfsp.SetMasterEncryptionKeyResp{}, nil
+}
+
+func (s *Server) getMasterKey(token string) (proto.Message, error) {
+	userID, err := s.authorize(token, rightMasterKey, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	encryptedKey, err := s.storage.GetMasterKey(userID)
+	if errors.Is(err, ErrNotFound) {
+		return nil, errors.New("master key not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &bfsp.GetMasterEncryptionKeyResp{
+		Response: &bfsp.GetMasterEncryptionKeyResp_EncryptedKey{EncryptedKey: encryptedKey},
+	}, nil
+}
@@ -0,0 +1,313 @@
+package server
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/BillysBigFileServer/bfsp-go"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
+)
+
+func newTestServer(t *testing.T, storage Storage) (*Server, string) {
+	t.Helper()
+	rootPubKey, rootKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := NewToken(rootKey, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(storage, rootPubKey), token
+}
+
+func uploadTestChunk(t *testing.T, srv *Server, token string, chunkID string, size int) *bfsp.UploadChunkResp {
+	t.Helper()
+	return uploadTestFileChunk(t, srv, token, chunkID, "", size)
+}
+
+// uploadTestFileChunk uploads a chunk saying it's part of fileID
+func uploadTestFileChunk(t *testing.T, srv *Server, token string, chunkID string, fileID string, size int) *bfsp.UploadChunkResp {
+	t.Helper()
+	return sendTestMessage(t, srv, token, &bfsp.FileServerMessage{
+		Message: &bfsp.FileServerMessage_UploadChunk_{
+			UploadChunk: &bfsp.FileServerMessage_UploadChunk{
+				ChunkMetadata: &bfsp.ChunkMetadata{Id: chunkID},
+				Chunk:         make([]byte, size),
+				FileId:        fileID,
+			},
+		},
+	}).(*bfsp.UploadChunkResp)
+}
+
+// sendTestMessage sends msg authenticated with token
+func sendTestMessage(t *testing.T, srv *Server, token string, msg *bfsp.FileServerMessage) proto.Message {
+	t.Helper()
+	msg.Auth = &bfsp.FileServerMessage_Authentication{Token: token}
+	resp, err := srv.HandleFileServerMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestServeHTTPRejectsOversizedMessages(t *testing.T) {
+	srv, _ := newTestServer(t, NewMemoryStorage())
+
+	// a length prefix claiming 4 GiB, with nothing after it
+	body := binary.LittleEndian.AppendUint32(nil, 0xffffffff)
+	req := httptest.NewRequest(http.MethodPost, "/api", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("got status %d, expected %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestReadFileServerMessageTruncated(t *testing.T) {
+	// the prefix promises more than is sent
+	msg := binary.LittleEndian.AppendUint32(nil, 100)
+	msg = append(msg, make([]byte, 10)...)
+	_, err := bfsp.ReadFileServerMessage(bytes.NewReader(msg))
+	if err == nil {
+		t.Fatal("expected an error for a truncated message")
+	}
+}
+
+func TestStorageCap(t *testing.T) {
+	srv, token := newTestServer(t, NewMemoryStorage())
+	srv.StorageCap = 1000
+
+	chunkID := uuid.NewString()
+	if resp := uploadTestChunk(t, srv, token, chunkID, 600); resp.Err != nil {
+		t.Fatalf("error uploading chunk under the cap: %s", *resp.Err)
+	}
+	if resp := uploadTestChunk(t, srv, token, uuid.NewString(), 600); resp.Err == nil {
+		t.Fatal("expected an error uploading a chunk over the cap")
+	}
+}
+
+func TestUploadChunkRefusesReplacing(t *testing.T) {
+	srv, token := newTestServer(t, NewMemoryStorage())
+
+	chunkID := uuid.NewString()
+	if resp := uploadTestChunk(t, srv, token, chunkID, 100); resp.Err != nil {
+		t.Fatalf("error uploading chunk: %s", *resp.Err)
+	}
+	// sending the same chunk again is fine, the response to the first might have been lost
+	if resp := uploadTestChunk(t, srv, token, chunkID, 100); resp.Err != nil {
+		t.Fatalf("error uploading the same chunk again: %s", *resp.Err)
+	}
+	if resp := uploadTestChunk(t, srv, token, chunkID, 200); resp.Err == nil {
+		t.Fatal("expected an error replacing a chunk")
+	}
+
+	chunk, err := srv.storage.GetChunk(1, chunkID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunk.Chunk) != 100 {
+		t.Fatalf("chunk is %d bytes, it was replaced", len(chunk.Chunk))
+	}
+}
+
+func TestDiskStorageUsage(t *testing.T) {
+	root := t.TempDir()
+	storage, err := NewDiskStorage(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chunkIDs := []string{uuid.NewString(), uuid.NewString(), uuid.NewString()}
+	for i, chunkID := range chunkIDs {
+		err := storage.PutChunk(1, chunkID, &bfsp.DownloadChunkResp_ChunkData{
+			ChunkMetadata: &bfsp.ChunkMetadata{Id: chunkID},
+			Chunk:         make([]byte, 100*(i+1)),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = storage.DeleteChunk(1, chunkIDs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	// deleting a chunk that doesn't exist doesn't change anything
+	err = storage.DeleteChunk(1, chunkIDs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	usage, err := storage.Usage(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage != 500 {
+		t.Fatalf("got usage %d, expected 500", usage)
+	}
+
+	// a new DiskStorage works the usage out from what's on disk
+	reopened, err := NewDiskStorage(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	usage, err = reopened.Usage(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage != 500 {
+		t.Fatalf("got usage %d after reopening, expected 500", usage)
+	}
+
+	usage, err = storage.Usage(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage != 0 {
+		t.Fatalf("got usage %d for a user without chunks, expected 0", usage)
+	}
+}
+
+func TestAuthorizeUsers(t *testing.T) {
+	rootPubKey, rootKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := New(NewMemoryStorage(), rootPubKey)
+	token, err := NewToken(rootKey, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunkID := uuid.NewString()
+	if resp := uploadTestChunk(t, srv, token, chunkID, 100); resp.Err != nil {
+		t.Fatalf("error uploading chunk: %s", *resp.Err)
+	}
+
+	otherUserToken, err := NewToken(rootKey, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherRootKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forgedToken, err := NewToken(otherRootKey, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokens := map[string]string{
+		"other user":      otherUserToken,
+		"other root key":  forgedToken,
+		"not a token":     "not a token",
+		"no token at all": "",
+	}
+	for name, token := range tokens {
+		t.Run(name, func(t *testing.T) {
+			resp, err := srv.HandleFileServerMessage(&bfsp.FileServerMessage{
+				Auth: &bfsp.FileServerMessage_Authentication{Token: token},
+				Message: &bfsp.FileServerMessage_DownloadChunkQuery_{
+					DownloadChunkQuery: &bfsp.FileServerMessage_DownloadChunkQuery{ChunkId: chunkID},
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := resp.(*bfsp.DownloadChunkResp).Response.(*bfsp.DownloadChunkResp_Err); !ok {
+				t.Fatal("downloaded the chunk")
+			}
+		})
+	}
+}
+
+func downloadTestChunk(t *testing.T, srv *Server, token string, chunkID string, fileID string) *bfsp.DownloadChunkResp {
+	t.Helper()
+	return sendTestMessage(t, srv, token, &bfsp.FileServerMessage{
+		Message: &bfsp.FileServerMessage_DownloadChunkQuery_{
+			DownloadChunkQuery: &bfsp.FileServerMessage_DownloadChunkQuery{ChunkId: chunkID, FileId: fileID},
+		},
+	}).(*bfsp.DownloadChunkResp)
+}
+
+func TestShareTokenOnlyReachesItsFilesChunks(t *testing.T) {
+	srv, token := newTestServer(t, NewMemoryStorage())
+	fileA, fileB := uuid.NewString(), uuid.NewString()
+	chunkA, chunkB := uuid.NewString(), uuid.NewString()
+	if resp := uploadTestFileChunk(t, srv, token, chunkA, fileA, 100); resp.Err != nil {
+		t.Fatalf("error uploading chunk: %s", *resp.Err)
+	}
+	if resp := uploadTestFileChunk(t, srv, token, chunkB, fileB, 100); resp.Err != nil {
+		t.Fatalf("error uploading chunk: %s", *resp.Err)
+	}
+	// a chunk from a client that doesn't say which file it's for
+	legacyChunk := uuid.NewString()
+	if resp := uploadTestChunk(t, srv, token, legacyChunk, 100); resp.Err != nil {
+		t.Fatalf("error uploading chunk: %s", *resp.Err)
+	}
+
+	masterKey := make(bfsp.MasterKey, 32)
+	rand.Read(masterKey)
+	view, err := bfsp.ShareFile(&bfsp.FileMetadata{Id: fileA}, token, masterKey, bfsp.WithShareAccess(bfsp.ShareReadWrite))
+	if err != nil {
+		t.Fatal(err)
+	}
+	shareToken := view.Token
+
+	if _, ok := downloadTestChunk(t, srv, shareToken, chunkA, fileA).Response.(*bfsp.DownloadChunkResp_ChunkData_); !ok {
+		t.Fatal("the share couldn't download its own file's chunk")
+	}
+	if resp := uploadTestFileChunk(t, srv, shareToken, uuid.NewString(), fileA, 100); resp.Err != nil {
+		t.Fatalf("the share couldn't upload a chunk to its own file: %s", *resp.Err)
+	}
+
+	downloads := map[string]struct{ chunkID, fileID string }{
+		"other file":                         {chunkB, fileB},
+		"other file's chunk as shared file":  {chunkB, fileA},
+		"other file's chunk without file id": {chunkB, ""},
+		"chunk without a file":               {legacyChunk, fileA},
+	}
+	for name, download := range downloads {
+		if _, ok := downloadTestChunk(t, srv, shareToken, download.chunkID, download.fileID).Response.(*bfsp.DownloadChunkResp_Err); !ok {
+			t.Errorf("%s: the share downloaded a chunk that isn't its file's", name)
+		}
+	}
+
+	if resp := uploadTestFileChunk(t, srv, shareToken, uuid.NewString(), fileB, 100); resp.Err == nil {
+		t.Error("the share uploaded a chunk to another file")
+	}
+	if resp := uploadTestFileChunk(t, srv, shareToken, uuid.NewString(), "", 100); resp.Err == nil {
+		t.Error("the share uploaded a chunk without saying which file it's for")
+	}
+
+	chunksUploadedResp := sendTestMessage(t, srv, shareToken, &bfsp.FileServerMessage{
+		Message: &bfsp.FileServerMessage_ChunksUploadedQuery_{
+			ChunksUploadedQuery: &bfsp.FileServerMessage_ChunksUploadedQuery{ChunkIds: []string{chunkB}},
+		},
+	}).(*bfsp.ChunksUploadedQueryResp)
+	if _, ok := chunksUploadedResp.Response.(*bfsp.ChunksUploadedQueryResp_Err); !ok {
+		t.Error("the share asked whether another file's chunk is uploaded")
+	}
+	listChunksResp := sendTestMessage(t, srv, shareToken, &bfsp.FileServerMessage{
+		Message: &bfsp.FileServerMessage_ListChunkMetadataQuery_{
+			ListChunkMetadataQuery: &bfsp.FileServerMessage_ListChunkMetadataQuery{Ids: []string{chunkB}},
+		},
+	}).(*bfsp.ListChunkMetadataResp)
+	if _, ok := listChunksResp.Response.(*bfsp.ListChunkMetadataResp_Err); !ok {
+		t.Error("the share listed another file's chunk")
+	}
+
+	// the owner's token isn't limited to some files, so a content addressed chunk can be read as part of any file it's in
+	if _, ok := downloadTestChunk(t, srv, token, chunkB, fileA).Response.(*bfsp.DownloadChunkResp_ChunkData_); !ok {
+		t.Fatal("the owner couldn't download a chunk recorded with another file")
+	}
+	if _, ok := downloadTestChunk(t, srv, token, legacyChunk, "").Response.(*bfsp.DownloadChunkResp_ChunkData_); !ok {
+		t.Fatal("the owner couldn't download a chunk without a file")
+	}
+}
@@ -0,0 +1,32 @@
+package server
+
+import (
+	"errors"
+
+	"github.com/BillysBigFileServer/bfsp-go"
+)
+
+var ErrNotFound = errors.New("not found")
+
+// Storage persists everything the file server knows about, namespaced by user. None of it can be read without the user's master key
+type Storage interface {
+	PutChunk(userID int64, chunkID string, chunk *bfsp.DownloadChunkResp_ChunkData) error
+	// GetChunk returns ErrNotFound if the chunk doesn't exist
+	GetChunk(userID int64, chunkID string) (*bfsp.DownloadChunkResp_ChunkData, error)
+	HasChunk(userID int64, chunkID string) (bool, error)
+	ListChunkIDs(userID int64) ([]string, error)
+	DeleteChunk(userID int64, chunkID string) error
+
+	PutFileMetadata(userID int64, meta *bfsp.EncryptedFileMetadata) error
+	// GetFileMetadata returns ErrNotFound if the file metadata doesn't exist
+	GetFileMetadata(userID int64, fileID string) (*bfsp.EncryptedFileMetadata, error)
+	ListFileMetadataIDs(userID int64) ([]string, error)
+	DeleteFileMetadata(userID int64, fileID string) error
+
+	PutMasterKey(userID int64, encryptedKey []byte) error
+	// GetMasterKey returns ErrNotFound if no master key has been stored
+	GetMasterKey(userID int64) ([]byte, error)
+
+	// Usage returns the total number of chunk bytes stored for a user
+	Usage(userID int64) (uint64, error)
+}
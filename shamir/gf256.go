@@ -0,0 +1,41 @@
+package shamir
+
+// arithmetic in GF(256) with the AES polynomial x^8 + x^4 + x^3 + x + 1. It's done without lookup tables, so how long it takes doesn't depend on the key
+
+// mul multiplies a and b
+func mul(a byte, b byte) byte {
+	var product byte
+	for i := 0; i < 8; i++ {
+		// -(b & 1) is all ones if the low bit of b is set, so a is added without branching on it
+		product ^= a & -(b & 1)
+		b >>= 1
+		// reduce a*x by the polynomial if it overflows
+		a = (a << 1) ^ (0x1b & -(a >> 7))
+	}
+	return product
+}
+
+// inverse returns a^-1, which is a^254 since a^255 = 1 for every nonzero a. The inverse of 0 is 0
+func inverse(a byte) byte {
+	result := a
+	// a^(2^k - 1) for k = 2..7 ends at a^127, squaring once more gives a^254
+	for i := 0; i < 6; i++ {
+		result = mul(mul(result, result), a)
+	}
+	return mul(result, result)
+}
+
+// div divides a by b, which can't be 0
+func div(a byte, b byte) byte {
+	return mul(a, inverse(b))
+}
+
+// evaluate evaluates the polynomial with coefficients, lowest degree first, at x
+func evaluate(coefficients []byte, x byte) byte {
+	// horner's method
+	var result byte
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		result = mul(result, x) ^ coefficients[i]
+	}
+	return result
+}
@@ -0,0 +1,50 @@
+package shamir
+
+import "testing"
+
+func TestMul(t *testing.T) {
+	// from FIPS 197, and 0x53 and 0xca are each other's inverse
+	tests := []struct{ a, b, product byte }{
+		{0x57, 0x83, 0xc1},
+		{0x57, 0x13, 0xfe},
+		{0x53, 0xca, 0x01},
+		{0x00, 0xff, 0x00},
+		{0x01, 0xab, 0xab},
+	}
+	for _, test := range tests {
+		if product := mul(test.a, test.b); product != test.product {
+			t.Errorf("mul(%#x, %#x) = %#x, expected %#x", test.a, test.b, product, test.product)
+		}
+		if product := mul(test.b, test.a); product != test.product {
+			t.Errorf("mul(%#x, %#x) = %#x, expected %#x", test.b, test.a, product, test.product)
+		}
+	}
+}
+
+func TestInverse(t *testing.T) {
+	if inverse(0x53) != 0xca {
+		t.Fatalf("inverse(0x53) = %#x, expected 0xca", inverse(0x53))
+	}
+	if inverse(0) != 0 {
+		t.Fatalf("inverse(0) = %#x, expected 0", inverse(0))
+	}
+	for a := 1; a < 256; a++ {
+		if product := mul(byte(a), inverse(byte(a))); product != 1 {
+			t.Fatalf("%#x * inverse(%#x) = %#x", a, a, product)
+		}
+		if quotient := div(byte(a), byte(a)); quotient != 1 {
+			t.Fatalf("%#x / %#x = %#x", a, a, quotient)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	coefficients := []byte{0x42, 0x57, 0x83}
+	// 0x42 + 0x57*x + 0x83*x^2
+	for x := 0; x < 256; x++ {
+		expected := 0x42 ^ mul(0x57, byte(x)) ^ mul(0x83, mul(byte(x), byte(x)))
+		if result := evaluate(coefficients, byte(x)); result != expected {
+			t.Fatalf("evaluate at %#x = %#x, expected %#x", x, result, expected)
+		}
+	}
+}
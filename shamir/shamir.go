@@ -0,0 +1,216 @@
+// Package shamir splits a master key into shares, any threshold of which can recover it, so a team's key doesn't depend on any one person.
+package shamir
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/BillysBigFileServer/bfsp-go"
+	"github.com/BillysBigFileServer/bfsp-go/config"
+)
+
+var (
+	ErrShareChecksum       = errors.New("share checksum doesn't match, it's probably mistyped")
+	ErrNotEnoughShares     = errors.New("not enough shares to recover the key")
+	ErrSharesMismatch      = errors.New("shares are from different keys")
+	ErrDuplicateShare      = errors.New("the same share was given more than once")
+	ErrFingerprintMismatch = errors.New("recovered key doesn't match the shares' key fingerprint, a share is probably corrupted or from a different split")
+)
+
+const shareVersion = 1
+
+// encoded shares are base32, which survives being read aloud or written down, after this prefix
+const sharePrefix = "bfsp-share-"
+
+const (
+	fingerprintSize = 16
+	checksumSize    = 4
+)
+
+// Share is one of the shares Split makes
+type Share struct {
+	// how many shares are needed to recover the key
+	Threshold byte
+	// x coordinate of the share, from 1 to the number of shares
+	Index byte
+	// bfsp.MasterKeyFingerprint of the key, so shares of different keys aren't combined and the recovered key can be checked.
+	// anyone with a share can check guesses of the key against it, which only matters for keys derived from weak passwords
+	Fingerprint []byte
+	// the key's polynomials evaluated at Index, one byte per key byte
+	Value []byte
+}
+
+// Split splits masterKey into n shares, any threshold of which recover it. Fewer than threshold shares give away nothing about the key
+func Split(masterKey bfsp.MasterKey, n int, threshold int) ([]*Share, error) {
+	if threshold < 2 || threshold > n || n > 255 {
+		return nil, fmt.Errorf("can't split a key into %d shares with a threshold of %d", n, threshold)
+	}
+	if len(masterKey) == 0 {
+		return nil, errors.New("can't split an empty key")
+	}
+
+	fingerprint := bfsp.MasterKeyFingerprint(masterKey)
+	shares := make([]*Share, n)
+	for i := range shares {
+		shares[i] = &Share{
+			Threshold:   byte(threshold),
+			Index:       byte(i + 1),
+			Fingerprint: fingerprint,
+			Value:       make([]byte, len(masterKey)),
+		}
+	}
+
+	// every byte of the key gets its own polynomial of degree threshold-1, with the byte as its constant term
+	coefficients := make([]byte, threshold)
+	for byteIndex, keyByte := range masterKey {
+		coefficients[0] = keyByte
+		_, err := rand.Read(coefficients[1:])
+		if err != nil {
+			return nil, err
+		}
+		for _, share := range shares {
+			share.Value[byteIndex] = evaluate(coefficients, share.Index)
+		}
+	}
+	clear(coefficients)
+
+	return shares, nil
+}
+
+// Combine recovers the key shares were split from. Every share has to be from the same split, and there has to be at least the threshold of them
+func Combine(shares []*Share) (bfsp.MasterKey, error) {
+	if len(shares) == 0 {
+		return nil, ErrNotEnoughShares
+	}
+
+	first := shares[0]
+	seen := map[byte]bool{}
+	for _, share := range shares {
+		if share.Threshold != first.Threshold || len(share.Value) != len(first.Value) || !bytes.Equal(share.Fingerprint, first.Fingerprint) {
+			return nil, ErrSharesMismatch
+		}
+		if share.Index == 0 {
+			return nil, fmt.Errorf("share index can't be 0")
+		}
+		if seen[share.Index] {
+			return nil, fmt.Errorf("%w: share %d", ErrDuplicateShare, share.Index)
+		}
+		seen[share.Index] = true
+	}
+	if len(shares) < int(first.Threshold) {
+		return nil, fmt.Errorf("%w: got %d, need %d", ErrNotEnoughShares, len(shares), first.Threshold)
+	}
+	// any threshold of the shares define the polynomials, extra ones aren't needed
+	shares = shares[:first.Threshold]
+
+	// lagrange interpolation at x = 0. The basis polynomials are the same for every byte of the key
+	basis := make([]byte, len(shares))
+	for i, share := range shares {
+		basis[i] = 1
+		for j, other := range shares {
+			if i == j {
+				continue
+			}
+			// other.Index / (other.Index - share.Index), subtraction is xor in GF(256)
+			basis[i] = mul(basis[i], div(other.Index, other.Index^share.Index))
+		}
+	}
+
+	masterKey := make(bfsp.MasterKey, len(first.Value))
+	for byteIndex := range masterKey {
+		var keyByte byte
+		for i, share := range shares {
+			keyByte ^= mul(basis[i], share.Value[byteIndex])
+		}
+		masterKey[byteIndex] = keyByte
+	}
+
+	if !bytes.Equal(bfsp.MasterKeyFingerprint(masterKey), first.Fingerprint) {
+		return nil, ErrFingerprintMismatch
+	}
+	return masterKey, nil
+}
+
+// RecoverToConfig combines encodedShares and writes the recovered key to cfg, the same way as logging in would
+func RecoverToConfig(cfg *config.Config, encodedShares []string) error {
+	shares := make([]*Share, len(encodedShares))
+	for i, encodedShare := range encodedShares {
+		share, err := DecodeShare(encodedShare)
+		if err != nil {
+			return fmt.Errorf("share %d: %w", i+1, err)
+		}
+		shares[i] = share
+	}
+
+	masterKey, err := Combine(shares)
+	if err != nil {
+		return err
+	}
+	cfg.SetEncryptionKey(masterKey)
+	return nil
+}
+
+// EncodeShare encodes share as text, with a checksum so a mistyped share is caught before it's combined
+func EncodeShare(share *Share) (string, error) {
+	if len(share.Fingerprint) != fingerprintSize {
+		return "", fmt.Errorf("share fingerprint is %d bytes, expected %d", len(share.Fingerprint), fingerprintSize)
+	}
+	if len(share.Value) > 255 {
+		return "", fmt.Errorf("share value is %d bytes, it can't be more than 255", len(share.Value))
+	}
+
+	bin := []byte{shareVersion, share.Threshold, share.Index, byte(len(share.Value))}
+	bin = append(bin, share.Fingerprint...)
+	bin = append(bin, share.Value...)
+	checksum := sha256.Sum256(bin)
+	bin = append(bin, checksum[:checksumSize]...)
+
+	return sharePrefix + strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(bin)), nil
+}
+
+// DecodeShare decodes a share from EncodeShare. Case, whitespace and dashes after the prefix don't matter, so shares can be written down in groups
+func DecodeShare(encodedShare string) (*Share, error) {
+	encodedShare = strings.ToLower(strings.TrimSpace(encodedShare))
+	if !strings.HasPrefix(encodedShare, sharePrefix) {
+		return nil, fmt.Errorf("share doesn't start with %q", sharePrefix)
+	}
+	encodedShare = strings.TrimPrefix(encodedShare, sharePrefix)
+	encodedShare = strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' || r == '\t' || r == '\n' || r == '\r' {
+			return -1
+		}
+		return r
+	}, encodedShare)
+
+	bin, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(encodedShare))
+	if err != nil {
+		return nil, err
+	}
+	if len(bin) < 4+fingerprintSize+checksumSize {
+		return nil, fmt.Errorf("share is too short")
+	}
+
+	checksum := sha256.Sum256(bin[:len(bin)-checksumSize])
+	if !bytes.Equal(checksum[:checksumSize], bin[len(bin)-checksumSize:]) {
+		return nil, ErrShareChecksum
+	}
+	if bin[0] != shareVersion {
+		return nil, fmt.Errorf("unsupported share version %d", bin[0])
+	}
+	valueSize := int(bin[3])
+	if len(bin) != 4+fingerprintSize+valueSize+checksumSize {
+		return nil, fmt.Errorf("share is %d bytes, expected %d", len(bin), 4+fingerprintSize+valueSize+checksumSize)
+	}
+
+	return &Share{
+		Threshold:   bin[1],
+		Index:       bin[2],
+		Fingerprint: bin[4 : 4+fingerprintSize],
+		Value:       bin[4+fingerprintSize : 4+fingerprintSize+valueSize],
+	}, nil
+}
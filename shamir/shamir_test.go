@@ -0,0 +1,191 @@
+package shamir
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/BillysBigFileServer/bfsp-go"
+	"github.com/BillysBigFileServer/bfsp-go/config"
+)
+
+func testMasterKey(t *testing.T) bfsp.MasterKey {
+	t.Helper()
+	masterKey := make(bfsp.MasterKey, 32)
+	_, err := rand.Read(masterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return masterKey
+}
+
+func TestCombineKnownAnswer(t *testing.T) {
+	// the polynomial 0x42 + 0x57x, so share 1 is 0x42^0x57 and share 2 is 0x42^mul(0x57, 2)
+	masterKey := bfsp.MasterKey{0x42}
+	fingerprint := bfsp.MasterKeyFingerprint(masterKey)
+	shares := []*Share{
+		{Threshold: 2, Index: 1, Fingerprint: fingerprint, Value: []byte{0x15}},
+		{Threshold: 2, Index: 2, Fingerprint: fingerprint, Value: []byte{0xec}},
+	}
+
+	recoveredKey, err := Combine(shares)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(recoveredKey, masterKey) {
+		t.Fatalf("recovered %x, expected %x", recoveredKey, masterKey)
+	}
+}
+
+func TestSplitCombine(t *testing.T) {
+	masterKey := testMasterKey(t)
+	shares, err := Split(masterKey, 5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every combination of at least 3 of the 5 shares
+	for subset := 0; subset < 1<<5; subset++ {
+		subsetShares := []*Share{}
+		for i, share := range shares {
+			if subset&(1<<i) != 0 {
+				subsetShares = append(subsetShares, share)
+			}
+		}
+
+		recoveredKey, err := Combine(subsetShares)
+		if len(subsetShares) < 3 {
+			if !errors.Is(err, ErrNotEnoughShares) {
+				t.Fatalf("got error %v combining %d shares, expected ErrNotEnoughShares", err, len(subsetShares))
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(recoveredKey, masterKey) {
+			t.Fatalf("shares %05b recovered the wrong key", subset)
+		}
+	}
+}
+
+func TestCombineErrors(t *testing.T) {
+	shares, err := Split(testMasterKey(t), 5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherShares, err := Split(testMasterKey(t), 5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	corruptedShare := *shares[2]
+	corruptedShare.Value = bytes.Clone(corruptedShare.Value)
+	corruptedShare.Value[0] ^= 1
+
+	tests := map[string]struct {
+		shares []*Share
+		err    error
+	}{
+		"duplicate":       {[]*Share{shares[0], shares[1], shares[0]}, ErrDuplicateShare},
+		"different split": {[]*Share{shares[0], shares[1], otherShares[2]}, ErrSharesMismatch},
+		"corrupted":       {[]*Share{shares[0], shares[1], &corruptedShare}, ErrFingerprintMismatch},
+		"none":            {nil, ErrNotEnoughShares},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Combine(test.shares)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("got error %v, expected %v", err, test.err)
+			}
+		})
+	}
+}
+
+func TestSplitInvalid(t *testing.T) {
+	tests := []struct{ n, threshold int }{
+		{5, 1},
+		{3, 4},
+		{256, 3},
+	}
+	for _, test := range tests {
+		_, err := Split(testMasterKey(t), test.n, test.threshold)
+		if err == nil {
+			t.Errorf("expected an error splitting into %d shares with a threshold of %d", test.n, test.threshold)
+		}
+	}
+}
+
+func TestEncodeDecodeShare(t *testing.T) {
+	shares, err := Split(testMasterKey(t), 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encodedShare, err := EncodeShare(shares[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// written down in upper case groups of 4
+	body := strings.TrimPrefix(encodedShare, sharePrefix)
+	groups := []string{}
+	for len(body) > 4 {
+		groups = append(groups, body[:4])
+		body = body[4:]
+	}
+	groups = append(groups, body)
+	writtenShare := " " + strings.ToUpper(sharePrefix+strings.Join(groups, "-")) + "\n"
+
+	for _, encoded := range []string{encodedShare, writtenShare} {
+		share, err := DecodeShare(encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if share.Threshold != 2 || share.Index != 2 || !bytes.Equal(share.Fingerprint, shares[1].Fingerprint) || !bytes.Equal(share.Value, shares[1].Value) {
+			t.Fatalf("decoded share %+v doesn't match %+v", share, shares[1])
+		}
+	}
+
+	// a mistyped character
+	mistyped := []byte(encodedShare)
+	i := len(sharePrefix) + 5
+	if mistyped[i] == 'a' {
+		mistyped[i] = 'b'
+	} else {
+		mistyped[i] = 'a'
+	}
+	_, err = DecodeShare(string(mistyped))
+	if !errors.Is(err, ErrShareChecksum) {
+		t.Fatalf("got error %v for a mistyped share, expected ErrShareChecksum", err)
+	}
+}
+
+func TestRecoverToConfig(t *testing.T) {
+	masterKey := testMasterKey(t)
+	shares, err := Split(masterKey, 5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encodedShares := []string{}
+	for _, share := range shares[2:] {
+		encodedShare, err := EncodeShare(share)
+		if err != nil {
+			t.Fatal(err)
+		}
+		encodedShares = append(encodedShares, encodedShare)
+	}
+
+	cfg := &config.Config{}
+	err = RecoverToConfig(cfg, encodedShares)
+	if err != nil {
+		t.Fatal(err)
+	}
+	configKey, err := cfg.EncryptionKeyBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(configKey, masterKey) {
+		t.Fatal("the config has the wrong key")
+	}
+}
@@ -0,0 +1,577 @@
+package bfsp_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/BillysBigFileServer/bfsp-go"
+	"github.com/BillysBigFileServer/bfsp-go/bfsptest"
+	"github.com/google/uuid"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/protobuf/proto"
+	"lukechampine.com/blake3"
+)
+
+// shareClient returns a client for srv that authenticates with a share's token
+func shareClient(t *testing.T, srv *bfsptest.Server, token string) bfsp.FileServerClient {
+	t.Helper()
+	cli, err := bfsp.NewDirectFileServerClient(token, srv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cli
+}
+
+func TestWriteOnlyShareCantReplaceChunks(t *testing.T) {
+	ctx, srv, masterKey := newTestContext(t)
+	fileMeta, data := uploadTestFile(t, ctx, "file", 100)
+
+	view, err := bfsp.ShareFile(fileMeta, srv.Token(), masterKey, bfsp.WithShareAccess(bfsp.ShareWriteOnly))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli := shareClient(t, srv, view.Token)
+
+	// a recipient of the share has the file's key, so they can encrypt a chunk that would decrypt fine in its place
+	replacement := []byte("replaced")
+	replacementHash := blake3.Sum256(replacement)
+	chunkMeta := &bfsp.ChunkMetadata{
+		Id:     fileMeta.Chunks[0],
+		Hash:   replacementHash[:],
+		Size:   uint32(len(replacement)),
+		Indice: 0,
+		Nonce:  randomBytes(t, 24),
+	}
+	encChunk, err := bfsp.CompressEncryptChunkWithDataKey(replacement, chunkMeta, fileMeta, masterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = bfsp.UploadChunkWithDataKey(cli, chunkMeta, fileMeta, *encChunk, masterKey)
+	if err == nil {
+		t.Fatal("a write only share replaced one of the file's chunks")
+	}
+
+	var downloaded bytes.Buffer
+	err = bfsp.DownloadFile(ctx, fileMeta, &downloaded, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(downloaded.Bytes(), data) {
+		t.Fatal("the file changed")
+	}
+}
+
+func TestShareExpiry(t *testing.T) {
+	ctx, srv, masterKey := newTestContext(t)
+	fileMeta, _ := uploadTestFile(t, ctx, "file", 100)
+
+	tests := map[string]struct {
+		expiry  time.Time
+		expired bool
+	}{
+		"expired":     {time.Now().Add(-time.Minute), true},
+		"not expired": {time.Now().Add(time.Hour), false},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			view, err := bfsp.ShareFile(fileMeta, srv.Token(), masterKey, bfsp.WithShareExpiry(test.expiry))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, _, err = bfsp.OpenSharedFile(srv.Client(), view)
+			if (err != nil) != test.expired {
+				t.Fatalf("got error %v opening a share that expires at %s", err, test.expiry)
+			}
+		})
+	}
+}
+
+func TestRevokeShare(t *testing.T) {
+	ctx, srv, masterKey := newTestContext(t)
+	fileMeta, data := uploadTestFile(t, ctx, "file", 100)
+
+	revokedShareID := uuid.NewString()
+	revokedView, err := bfsp.ShareFile(fileMeta, srv.Token(), masterKey, bfsp.WithShareID(revokedShareID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherView, err := bfsp.ShareFile(fileMeta, srv.Token(), masterKey, bfsp.WithShareID(uuid.NewString()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = bfsp.RevokeShare(srv.Client(), revokedShareID, fileMeta.Id, masterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = bfsp.OpenSharedFile(srv.Client(), revokedView)
+	if err == nil {
+		t.Fatal("opened a revoked share")
+	}
+	checkSharedFile(t, srv, otherView, data)
+
+	revocations, err := bfsp.ListShareRevocations(srv.Client(), masterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revocations) != 1 || revocations[0].ShareId != revokedShareID || revocations[0].FileId != fileMeta.Id {
+		t.Fatalf("got revocations %v", revocations)
+	}
+	// the revocation list is stored as file metadata, but it isn't a file
+	fileMetas, err := bfsp.ListFileMetadata(srv.Client(), nil, masterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fileMetas) != 1 {
+		t.Fatalf("listed %d files, expected 1", len(fileMetas))
+	}
+
+	// another share can't change the revocation list, even one that can write
+	readWriteView, err := bfsp.ShareFile(fileMeta, srv.Token(), masterKey, bfsp.WithShareAccess(bfsp.ShareReadWrite))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = bfsp.UnrevokeShare(shareClient(t, srv, readWriteView.Token), revokedShareID, masterKey)
+	if err == nil {
+		t.Fatal("a share changed the revocation list")
+	}
+
+	// the revocation list moves to the new key with everything else
+	newKey := bfsp.MasterKey(randomBytes(t, 32))
+	err = bfsp.RotateMasterKey(ctx, masterKey, newKey, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	revocations, err = bfsp.ListShareRevocations(srv.Client(), newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revocations) != 1 {
+		t.Fatalf("got %d revocations after rotating, expected 1", len(revocations))
+	}
+
+	err = bfsp.UnrevokeShare(srv.Client(), revokedShareID, newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSharedFile(t, srv, revokedView, data)
+}
+
+func TestRevokeSharesConcurrently(t *testing.T) {
+	ctx, srv, masterKey := newTestContext(t)
+	fileMeta, _ := uploadTestFile(t, ctx, "file", 100)
+
+	// every revocation reads the list before any of them writes it
+	cli, err := bfsp.NewDirectFileServerClient(srv.Token(), &revocationRaceServer{Server: srv, delay: 5 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	shareIDs := map[string]bool{}
+	for range 16 {
+		shareIDs[uuid.NewString()] = true
+	}
+	g := errgroup.Group{}
+	for shareID := range shareIDs {
+		g.Go(func() error {
+			return bfsp.RevokeShare(cli, shareID, fileMeta.Id, masterKey)
+		})
+	}
+	err = g.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	revocations, err := bfsp.ListShareRevocations(srv.Client(), masterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revocations) != len(shareIDs) {
+		t.Fatalf("got %d revocations, expected %d", len(revocations), len(shareIDs))
+	}
+	for _, revocation := range revocations {
+		if !shareIDs[revocation.ShareId] {
+			t.Fatalf("got revocation of share %s, which wasn't revoked", revocation.ShareId)
+		}
+	}
+}
+
+// revocationRaceServer is a bfsptest server that makes races over the share revocation list likely. It handles every message after delay,
+// and runs afterUpdate once, right after the list is next updated
+type revocationRaceServer struct {
+	*bfsptest.Server
+	delay       time.Duration
+	afterUpdate func()
+}
+
+func (s *revocationRaceServer) HandleFileServerMessage(msg *bfsp.FileServerMessage) (proto.Message, error) {
+	time.Sleep(s.delay)
+	resp, err := s.Server.HandleFileServerMessage(msg)
+	update, ok := msg.Message.(*bfsp.FileServerMessage_UpdateFileMetadata_)
+	if ok && update.UpdateFileMetadata.EncryptedFileMetadata.GetId() == bfsp.ShareRevocationsID && s.afterUpdate != nil {
+		s.afterUpdate()
+		s.afterUpdate = nil
+	}
+	return resp, err
+}
+
+func TestRevokeShareRetriesLostUpdate(t *testing.T) {
+	_, srv, masterKey := newTestContext(t)
+	fileID := uuid.NewString()
+	firstShareID, otherShareID, shareID := uuid.NewString(), uuid.NewString(), uuid.NewString()
+
+	// the list another device writes, having read it before this revocation
+	for _, id := range []string{firstShareID, otherShareID} {
+		err := bfsp.RevokeShare(srv.Client(), id, fileID, masterKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	otherList, err := srv.Storage.GetFileMetadata(bfsptest.UserID, bfsp.ShareRevocationsID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = bfsp.UnrevokeShare(srv.Client(), otherShareID, masterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raceSrv := &revocationRaceServer{Server: srv}
+	raceSrv.afterUpdate = func() {
+		err := srv.Storage.PutFileMetadata(bfsptest.UserID, otherList)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	cli, err := bfsp.NewDirectFileServerClient(srv.Token(), raceSrv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = bfsp.RevokeShare(cli, shareID, fileID, masterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	revocations, err := bfsp.ListShareRevocations(srv.Client(), masterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked := map[string]bool{}
+	for _, revocation := range revocations {
+		revoked[revocation.ShareId] = true
+	}
+	if len(revoked) != 3 || !revoked[firstShareID] || !revoked[otherShareID] || !revoked[shareID] {
+		t.Fatalf("got revocations %v, expected both devices' revocations", revocations)
+	}
+}
+
+// checkSharedFile checks that the file shared with view can be opened, and has data in it
+func checkSharedFile(t *testing.T, srv *bfsptest.Server, view *bfsp.ViewFileInfo, data []byte) {
+	t.Helper()
+	_, fileReader, err := bfsp.OpenSharedFile(srv.Client(), view)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fileReader.Close()
+	sharedData, err := io.ReadAll(fileReader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(sharedData, data) {
+		t.Fatal("shared file doesn't match what was uploaded")
+	}
+}
+
+func TestShareContentAddressedFile(t *testing.T) {
+	ctx, srv, masterKey := newTestContext(t)
+	fileMeta, _ := uploadTestFile(t, ctx, "file", 100, bfsp.WithContentDefinedChunking())
+
+	_, err := bfsp.ShareFile(fileMeta, srv.Token(), masterKey)
+	if err == nil {
+		t.Fatal("shared a content addressed file")
+	}
+	_, err = bfsp.ShareFiles(srv.Client(), []*bfsp.FileMetadata{fileMeta}, srv.Token(), masterKey)
+	if err == nil {
+		t.Fatal("shared a content addressed file with ShareFiles")
+	}
+}
+
+// checkShare checks that the share from view has exactly the files in files, keyed by id, with their data
+func checkShare(t *testing.T, srv *bfsptest.Server, view *bfsp.ViewShareInfo, files map[string][]byte) *bfsp.SharedFiles {
+	t.Helper()
+	share, err := bfsp.OpenShare(srv.Client(), view)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sharedFiles := share.Files()
+	if len(sharedFiles) != len(files) {
+		t.Fatalf("share has %d files, expected %d", len(sharedFiles), len(files))
+	}
+	for _, fileMeta := range sharedFiles {
+		data, ok := files[fileMeta.Id]
+		if !ok {
+			t.Fatalf("share has file %s, which wasn't shared", fileMeta.Id)
+		}
+		var downloaded bytes.Buffer
+		err := share.Download(context.Background(), fileMeta.Id, &downloaded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(downloaded.Bytes(), data) {
+			t.Fatalf("shared file %s doesn't match what was uploaded", fileMeta.FileName)
+		}
+	}
+	return share
+}
+
+func TestShareFiles(t *testing.T) {
+	ctx, srv, masterKey := newTestContext(t)
+	fileMeta1, data1 := uploadTestFile(t, ctx, "file1", 100)
+	fileMeta2, data2 := uploadTestFile(t, ctx, "file2", bfsp.ChunkSize+100)
+	notSharedMeta, _ := uploadTestFile(t, ctx, "file3", 100)
+
+	view, err := bfsp.ShareFiles(srv.Client(), []*bfsp.FileMetadata{fileMeta1, fileMeta2}, srv.Token(), masterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkShare(t, srv, view, map[string][]byte{fileMeta1.Id: data1, fileMeta2.Id: data2})
+
+	_, err = bfsp.DownloadFileMetadata(shareClient(t, srv, view.Token), notSharedMeta.Id, masterKey)
+	if err == nil {
+		t.Fatal("the share's token could download a file that wasn't shared")
+	}
+
+	// the manifest is stored as file metadata, but it isn't a file
+	fileMetas, err := bfsp.ListFileMetadata(srv.Client(), nil, masterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fileMetas) != 3 {
+		t.Fatalf("listed %d files, expected 3", len(fileMetas))
+	}
+	if _, ok := fileMetas[view.ManifestId]; ok {
+		t.Fatal("the share manifest was listed as a file")
+	}
+}
+
+func TestShareManifestReadOnly(t *testing.T) {
+	ctx, srv, masterKey := newTestContext(t)
+	fileMeta, data := uploadTestFile(t, ctx, "file", 100)
+
+	view, err := bfsp.ShareFiles(srv.Client(), []*bfsp.FileMetadata{fileMeta}, srv.Token(), masterKey, bfsp.WithShareAccess(bfsp.ShareReadWrite))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli := shareClient(t, srv, view.Token)
+
+	// the share can write its files, but not replace the manifest with one listing other keys
+	err = bfsp.UpdateFileMetadata(cli, fileMeta, masterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = bfsp.UpdateFileMetadata(cli, &bfsp.FileMetadata{Id: view.ManifestId, FileName: "manifest"}, masterKey)
+	if err == nil {
+		t.Fatal("a share replaced its manifest")
+	}
+	checkShare(t, srv, view, map[string][]byte{fileMeta.Id: data})
+}
+
+func TestDeleteShare(t *testing.T) {
+	ctx, srv, masterKey := newTestContext(t)
+	fileMeta, _ := uploadTestFile(t, ctx, "file", 100)
+
+	view, err := bfsp.ShareFiles(srv.Client(), []*bfsp.FileMetadata{fileMeta}, srv.Token(), masterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifestIDs, err := bfsp.ListShares(srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(manifestIDs, []string{view.ManifestId}) {
+		t.Fatalf("listed shares %v, expected %s", manifestIDs, view.ManifestId)
+	}
+
+	// only manifests can be deleted this way
+	err = bfsp.DeleteShare(srv.Client(), fileMeta.Id)
+	if err == nil {
+		t.Fatal("deleted a file as a share")
+	}
+	// a share can't delete itself
+	err = bfsp.DeleteShare(shareClient(t, srv, view.Token), view.ManifestId)
+	if err == nil {
+		t.Fatal("a share deleted its manifest")
+	}
+
+	err = bfsp.DeleteShare(srv.Client(), view.ManifestId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = bfsp.OpenShare(srv.Client(), view)
+	if err == nil {
+		t.Fatal("opened a deleted share")
+	}
+	manifestIDs, err = bfsp.ListShares(srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifestIDs) != 0 {
+		t.Fatalf("listed shares %v after deleting the only one", manifestIDs)
+	}
+	_, err = bfsp.DownloadFileMetadata(srv.Client(), fileMeta.Id, masterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestShareDirectory(t *testing.T) {
+	ctx, srv, masterKey := newTestContext(t)
+	files := map[string][]byte{}
+	for name, directory := range map[string][]string{
+		"shared":       {"photos"},
+		"nested":       {"photos", "2024"},
+		"other":        {"documents"},
+		"similar name": {"photos2"},
+	} {
+		fileMeta, data := uploadTestFile(t, ctx, name, 100)
+		fileMeta.Directory = directory
+		err := bfsp.UpdateFileMetadata(srv.Client(), fileMeta, masterKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if directory[0] == "photos" {
+			files[fileMeta.Id] = data
+		}
+	}
+
+	view, err := bfsp.ShareDirectory(srv.Client(), []string{"photos"}, srv.Token(), masterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	share := checkShare(t, srv, view, files)
+	if !slices.Equal(share.Directory, []string{"photos"}) {
+		t.Fatalf("share is of directory %v, expected photos", share.Directory)
+	}
+
+	_, err = bfsp.ShareDirectory(srv.Client(), nil, srv.Token(), masterKey)
+	if err == nil {
+		t.Fatal("shared every file by sharing no directory")
+	}
+	_, err = bfsp.ShareDirectory(srv.Client(), []string{"music"}, srv.Token(), masterKey)
+	if err == nil {
+		t.Fatal("shared a directory with no files in it")
+	}
+}
+
+// shareActions are the things a share's recipient might try with a file, each returning the server's error
+func shareActions(t *testing.T, srv *bfsptest.Server, view *bfsp.ViewFileInfo, fileMeta *bfsp.FileMetadata, masterKey bfsp.MasterKey) map[string]func() error {
+	cli := shareClient(t, srv, view.Token)
+	return map[string]func() error{
+		"download chunk": func() error {
+			_, err := bfsp.DownloadChunk(cli, chunkArgs(fileMeta, 0), masterKey)
+			return err
+		},
+		"upload chunk": func() error {
+			data := randomBytes(t, 100)
+			chunkHash := blake3.Sum256(data)
+			chunkMeta := &bfsp.ChunkMetadata{
+				Id:     uuid.NewString(),
+				Hash:   chunkHash[:],
+				Size:   uint32(len(data)),
+				Indice: int64(len(fileMeta.Chunks)),
+				Nonce:  randomBytes(t, 24),
+			}
+			encChunk, err := bfsp.CompressEncryptChunkWithDataKey(data, chunkMeta, fileMeta, masterKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return bfsp.UploadChunkWithDataKey(cli, chunkMeta, fileMeta, *encChunk, masterKey)
+		},
+		"update metadata": func() error {
+			return bfsp.UpdateFileMetadata(cli, fileMeta, masterKey)
+		},
+		"open shared file": func() error {
+			_, fileReader, err := bfsp.OpenSharedFile(srv.Client(), view)
+			if err != nil {
+				return err
+			}
+			defer fileReader.Close()
+			_, err = io.Copy(io.Discard, fileReader)
+			return err
+		},
+	}
+}
+
+func TestShareAccess(t *testing.T) {
+	ctx, srv, masterKey := newTestContext(t)
+	fileMeta, _ := uploadTestFile(t, ctx, "file", 100)
+
+	tests := map[string]struct {
+		access  bfsp.ShareAccess
+		allowed map[string]bool
+	}{
+		"read only":  {bfsp.ShareReadOnly, map[string]bool{"download chunk": true, "open shared file": true}},
+		"write only": {bfsp.ShareWriteOnly, map[string]bool{"upload chunk": true, "update metadata": true}},
+		"read write": {bfsp.ShareReadWrite, map[string]bool{"download chunk": true, "open shared file": true, "upload chunk": true, "update metadata": true}},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			view, err := bfsp.ShareFile(fileMeta, srv.Token(), masterKey, bfsp.WithShareAccess(test.access))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for action, do := range shareActions(t, srv, view, fileMeta, masterKey) {
+				err := do()
+				if test.allowed[action] && err != nil {
+					t.Errorf("%s: %s", action, err)
+				}
+				if !test.allowed[action] && err == nil {
+					t.Errorf("%s was allowed", action)
+				}
+			}
+		})
+	}
+}
+
+func TestShareOnlyReachesItsFile(t *testing.T) {
+	ctx, srv, masterKey := newTestContext(t)
+	sharedMeta, _ := uploadTestFile(t, ctx, "shared", 100)
+	otherMeta, otherData := uploadTestFile(t, ctx, "other", 100)
+
+	view, err := bfsp.ShareFile(sharedMeta, srv.Token(), masterKey, bfsp.WithShareAccess(bfsp.ShareReadWrite))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// everything needed for the other file, except that the token is the shared file's
+	otherView, err := bfsp.ShareFile(otherMeta, srv.Token(), masterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherView.Token = view.Token
+	for name, action := range shareActions(t, srv, otherView, otherMeta, masterKey) {
+		if err := action(); err == nil {
+			t.Errorf("%s was allowed on a file that wasn't shared", name)
+		}
+	}
+
+	// naming the shared file doesn't get another file's chunk
+	cli := shareClient(t, srv, view.Token)
+	args := chunkArgs(otherMeta, 0)
+	args.FileID = sharedMeta.Id
+	_, err = bfsp.DownloadChunk(cli, args, masterKey)
+	if err == nil {
+		t.Fatal("downloaded another file's chunk by naming the shared file")
+	}
+
+	var downloaded bytes.Buffer
+	err = bfsp.DownloadFile(ctx, otherMeta, &downloaded, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(downloaded.Bytes(), otherData) {
+		t.Fatal("the file that wasn't shared changed")
+	}
+}
@@ -0,0 +1,233 @@
+package bfsp
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/BillysBigFileServer/bfsp-go/config"
+	"github.com/google/uuid"
+	"github.com/klauspost/compress/zstd"
+	"golang.org/x/crypto/chacha20poly1305"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	ErrShareLinkMalformed = errors.New("share link is malformed")
+	ErrShareLinkTruncated = errors.New("share link is truncated, it was probably cut off when it was copied")
+	ErrShareLinkVersion   = errors.New("share link version isn't supported, the link is probably from a newer client")
+)
+
+const (
+	shareLinkVersion = 1
+	shareLinkPath    = "/files/view"
+	// a ViewFileInfo or ViewShareInfo is a token and a key, far smaller than this. It stops a tiny link decompressing into something huge
+	maxShareLinkViewSize = 1 << 20
+)
+
+// ShareLink is a link to a file shared with ShareFile, or files shared with ShareFiles or ShareDirectory.
+// the ViewFileInfo or ViewShareInfo goes in the URL's fragment, which browsers never send to servers, so the keys stay between whoever has the link
+type ShareLink struct {
+	// BaseURL is the big-central the link opens in
+	BaseURL string
+	// only one of View and Share is set
+	View  *ViewFileInfo
+	Share *ViewShareInfo
+}
+
+// NewShareLink creates a link to view against config.BigCentralBaseURL
+func NewShareLink(view *ViewFileInfo) *ShareLink {
+	return &ShareLink{
+		BaseURL: config.BigCentralBaseURL(),
+		View:    view,
+	}
+}
+
+// NewFilesShareLink creates a link to share against config.BigCentralBaseURL
+func NewFilesShareLink(share *ViewShareInfo) *ShareLink {
+	return &ShareLink{
+		BaseURL: config.BigCentralBaseURL(),
+		Share:   share,
+	}
+}
+
+// URL renders the link as <BaseURL>/files/view#v=1&view=<ViewFileInfo>, or share=<ViewShareInfo> instead of view, encoded like EncodeViewFileInfo.
+// this is the only link format, EncodeViewFileInfo on its own is for putting a view somewhere other than a link
+func (link *ShareLink) URL() (string, error) {
+	baseURL, err := url.Parse(link.BaseURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid base url: %w", err)
+	}
+
+	var fragmentKey string
+	var encodedView string
+	switch {
+	case link.View != nil && link.Share == nil:
+		fragmentKey = "view"
+		encodedView, err = encodeShareLinkMessage(link.View)
+	case link.Share != nil && link.View == nil:
+		fragmentKey = "share"
+		encodedView, err = encodeShareLinkMessage(link.Share)
+	default:
+		return "", errors.New("share link needs exactly one of View and Share")
+	}
+	if err != nil {
+		return "", err
+	}
+
+	fragment := url.Values{}
+	fragment.Set("v", strconv.Itoa(shareLinkVersion))
+	fragment.Set(fragmentKey, encodedView)
+
+	linkURL := baseURL.JoinPath(shareLinkPath)
+	linkURL.RawQuery = ""
+	// url.Values.Encode sorts by key, so the version comes first. It's already escaped, so it's set as is
+	linkURL.RawFragment = fragment.Encode()
+	linkURL.Fragment, err = url.PathUnescape(linkURL.RawFragment)
+	if err != nil {
+		return "", err
+	}
+	return linkURL.String(), nil
+}
+
+// ParseShareLink parses a link from ShareLink.URL. Errors wrap ErrShareLinkMalformed, ErrShareLinkTruncated or ErrShareLinkVersion
+func ParseShareLink(link string) (*ShareLink, error) {
+	link = strings.TrimSpace(link)
+	// the base64 padding is escaped, and a link cut off in the middle of an escape isn't malformed
+	if i := strings.LastIndexByte(link, '%'); i >= 0 && i >= len(link)-2 {
+		return nil, fmt.Errorf("%w: it ends in the middle of an escape", ErrShareLinkTruncated)
+	}
+	linkURL, err := url.Parse(link)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrShareLinkMalformed, err)
+	}
+	if linkURL.Fragment == "" {
+		return nil, fmt.Errorf("%w: it has no fragment", ErrShareLinkTruncated)
+	}
+
+	fragment, err := url.ParseQuery(linkURL.EscapedFragment())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrShareLinkMalformed, err)
+	}
+	if fragment.Get("v") == "" {
+		return nil, fmt.Errorf("%w: it has no version", ErrShareLinkTruncated)
+	}
+	version, err := strconv.Atoi(fragment.Get("v"))
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid version %q", ErrShareLinkMalformed, fragment.Get("v"))
+	}
+	if version != shareLinkVersion {
+		return nil, fmt.Errorf("%w: version %d", ErrShareLinkVersion, version)
+	}
+
+	shareLink := &ShareLink{}
+	switch {
+	case fragment.Has("view"):
+		shareLink.View, err = decodeShareLinkView(fragment.Get("view"))
+	case fragment.Has("share"):
+		shareLink.Share, err = decodeShareLinkShare(fragment.Get("share"))
+	default:
+		return nil, fmt.Errorf("%w: it has no file", ErrShareLinkTruncated)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	linkURL.Fragment = ""
+	linkURL.RawFragment = ""
+	shareLink.BaseURL = strings.TrimSuffix(linkURL.String(), shareLinkPath)
+	return shareLink, nil
+}
+
+func decodeShareLinkView(encodedView string) (*ViewFileInfo, error) {
+	var view ViewFileInfo
+	err := decodeShareLinkMessage(encodedView, &view)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := uuid.Parse(view.Id); err != nil {
+		return nil, fmt.Errorf("%w: invalid file id %q", ErrShareLinkMalformed, view.Id)
+	}
+	if view.Token == "" {
+		return nil, fmt.Errorf("%w: it has no token", ErrShareLinkMalformed)
+	}
+	fileKey, err := base64.URLEncoding.DecodeString(view.FileEncKey)
+	if err != nil || len(fileKey) != chacha20poly1305.KeySize {
+		return nil, fmt.Errorf("%w: invalid file key", ErrShareLinkMalformed)
+	}
+
+	return &view, nil
+}
+
+func decodeShareLinkShare(encodedShare string) (*ViewShareInfo, error) {
+	var share ViewShareInfo
+	err := decodeShareLinkMessage(encodedShare, &share)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := uuid.Parse(share.ManifestId); err != nil {
+		return nil, fmt.Errorf("%w: invalid manifest id %q", ErrShareLinkMalformed, share.ManifestId)
+	}
+	if share.Token == "" {
+		return nil, fmt.Errorf("%w: it has no token", ErrShareLinkMalformed)
+	}
+	shareKey, err := base64.URLEncoding.DecodeString(share.ShareKey)
+	if err != nil || len(shareKey) != chacha20poly1305.KeySize {
+		return nil, fmt.Errorf("%w: invalid share key", ErrShareLinkMalformed)
+	}
+
+	return &share, nil
+}
+
+// encodeShareLinkMessage zstd compresses msg and base64 encodes it, for a link's fragment or EncodeViewFileInfo
+func encodeShareLinkMessage(msg proto.Message) (string, error) {
+	msgBin, err := proto.Marshal(msg)
+	if err != nil {
+		return "", err
+	}
+	compressedMsg, err := compress(msgBin, CompressionBest, false)
+	if err != nil {
+		return "", err
+	}
+	defer putCompressBuffer(compressedMsg)
+
+	return base64.URLEncoding.EncodeToString(compressedMsg), nil
+}
+
+// decodeShareLinkMessage decodes msg from encodeShareLinkMessage
+func decodeShareLinkMessage(encodedMsg string, msg proto.Message) error {
+	// padded base64 is always a multiple of 4 characters long, so the end of the link is missing
+	if len(encodedMsg) == 0 || len(encodedMsg)%4 != 0 {
+		return ErrShareLinkTruncated
+	}
+	compressedMsg, err := base64.URLEncoding.DecodeString(encodedMsg)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrShareLinkMalformed, err)
+	}
+
+	zstdDecoder, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxMemory(maxShareLinkViewSize))
+	if err != nil {
+		return err
+	}
+	defer zstdDecoder.Close()
+
+	msgBin, err := zstdDecoder.DecodeAll(compressedMsg, nil)
+	if errors.Is(err, io.ErrUnexpectedEOF) || (err == nil && len(msgBin) == 0) {
+		return ErrShareLinkTruncated
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrShareLinkMalformed, err)
+	}
+
+	err = proto.Unmarshal(msgBin, msg)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrShareLinkMalformed, err)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package bfsp_test
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/BillysBigFileServer/bfsp-go"
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
+)
+
+func testShareLinks(t *testing.T) map[string]*bfsp.ShareLink {
+	t.Helper()
+	key := base64.URLEncoding.EncodeToString(randomBytes(t, 32))
+	return map[string]*bfsp.ShareLink{
+		"file": {
+			BaseURL: "https://bbfs.io",
+			View:    &bfsp.ViewFileInfo{Id: uuid.NewString(), Token: "token", FileEncKey: key},
+		},
+		"files": {
+			BaseURL: "https://example.com/bbfs",
+			Share:   &bfsp.ViewShareInfo{ManifestId: uuid.NewString(), Token: "token", ShareKey: key},
+		},
+	}
+}
+
+func TestShareLinkRoundTrip(t *testing.T) {
+	for name, link := range testShareLinks(t) {
+		t.Run(name, func(t *testing.T) {
+			linkURL, err := link.URL()
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsedLink, err := bfsp.ParseShareLink(" " + linkURL + "\n")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if parsedLink.BaseURL != link.BaseURL || !proto.Equal(parsedLink.View, link.View) || !proto.Equal(parsedLink.Share, link.Share) {
+				t.Fatalf("parsed %+v, expected %+v", parsedLink, link)
+			}
+		})
+	}
+}
+
+func TestShareLinkTruncated(t *testing.T) {
+	for name, link := range testShareLinks(t) {
+		t.Run(name, func(t *testing.T) {
+			linkURL, err := link.URL()
+			if err != nil {
+				t.Fatal(err)
+			}
+			base, fragment, _ := strings.Cut(linkURL, "#")
+			payloadStart := strings.Index(fragment, "=") + 1
+			payloadStart += strings.Index(fragment[payloadStart:], "=") + 1
+
+			truncated := []string{
+				base,
+				base + "#",
+				base + "#v=1",
+				base + "#" + fragment[:payloadStart],
+				base + "#" + fragment[:payloadStart+(len(fragment)-payloadStart)/2],
+				linkURL[:len(linkURL)-1],
+			}
+			for _, truncatedURL := range truncated {
+				_, err := bfsp.ParseShareLink(truncatedURL)
+				if !errors.Is(err, bfsp.ErrShareLinkTruncated) {
+					t.Errorf("got error %v parsing %q, expected ErrShareLinkTruncated", err, truncatedURL)
+				}
+			}
+
+			// wherever it's cut off, it's an error rather than a broken link
+			for i := len(base); i < len(linkURL); i++ {
+				_, err := bfsp.ParseShareLink(linkURL[:i])
+				if !errors.Is(err, bfsp.ErrShareLinkTruncated) && !errors.Is(err, bfsp.ErrShareLinkMalformed) {
+					t.Fatalf("got error %v parsing the link cut off after %d characters", err, i)
+				}
+			}
+		})
+	}
+}
+
+func TestShareLinkErrors(t *testing.T) {
+	link := testShareLinks(t)["file"]
+	linkURL, err := link.URL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, fragment, _ := strings.Cut(linkURL, "#")
+	values := strings.TrimPrefix(fragment, "v=1&")
+
+	garbage := base64.URLEncoding.EncodeToString([]byte("definitely not zstd"))
+	tests := map[string]struct {
+		link string
+		err  error
+	}{
+		"newer version":  {base + "#v=2&" + values, bfsp.ErrShareLinkVersion},
+		"bad version":    {base + "#v=one&" + values, bfsp.ErrShareLinkMalformed},
+		"bad base64":     {base + "#v=1&view=!!!!", bfsp.ErrShareLinkMalformed},
+		"not compressed": {base + "#v=1&view=" + garbage, bfsp.ErrShareLinkMalformed},
+		"bad url":        {"http://[::1" + "#" + fragment, bfsp.ErrShareLinkMalformed},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := bfsp.ParseShareLink(test.link)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("got error %v, expected %v", err, test.err)
+			}
+		})
+	}
+}
+
+// a link's view is encoded the same as EncodeViewFileInfo, so either can be decoded wherever the other is expected
+func TestShareLinkMatchesEncodeViewFileInfo(t *testing.T) {
+	link := testShareLinks(t)["file"]
+	linkURL, err := link.URL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	encodedView, err := bfsp.EncodeViewFileInfo(link.View)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsedURL, err := url.Parse(linkURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fragment, err := url.ParseQuery(parsedURL.EscapedFragment())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fragment.Get("view") != encodedView {
+		t.Fatalf("link has view %q, EncodeViewFileInfo gave %q", fragment.Get("view"), encodedView)
+	}
+
+	view, err := bfsp.DecodeViewFileInfoB64(encodedView)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(view, link.View) {
+		t.Fatalf("decoded %+v, expected %+v", view, link.View)
+	}
+	_, err = bfsp.DecodeViewFileInfoB64(encodedView[:len(encodedView)-1])
+	if !errors.Is(err, bfsp.ErrShareLinkTruncated) {
+		t.Fatalf("got error %v decoding a cut off view, expected ErrShareLinkTruncated", err)
+	}
+}
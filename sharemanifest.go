@@ -0,0 +1,323 @@
+package bfsp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/chacha20poly1305"
+	"google.golang.org/protobuf/proto"
+)
+
+// a share's manifest is stored as file metadata starting with this, then the encrypted ShareManifest, so it's never mistaken for a file's
+const shareManifestMagic = "bfspman1"
+
+// ShareFiles shares fileMetas in one share, whose token only gives access to them and their manifest.
+// the manifest lists their ids and keys, and is stored on the server as file metadata, encrypted with a new share key that's only in the returned ViewShareInfo.
+// it stays there until it's deleted with DeleteShare. Every file id is in the token, so sharing thousands of files makes for a long link
+func ShareFiles(cli FileServerClient, fileMetas []*FileMetadata, tokenStr string, masterKey MasterKey, opts ...ShareOption) (*ViewShareInfo, error) {
+	return shareFiles(cli, fileMetas, nil, tokenStr, masterKey, opts)
+}
+
+// ShareDirectory shares every file in directory, including the ones in directories below it, like ShareFiles. Files added to it later aren't in the share.
+// directory can't be empty, since sharing everything is never what's meant by a missing directory
+func ShareDirectory(cli FileServerClient, directory []string, tokenStr string, masterKey MasterKey, opts ...ShareOption) (*ViewShareInfo, error) {
+	if len(directory) == 0 {
+		return nil, errors.New("can't share the root directory, share its files with ShareFiles instead")
+	}
+	allFileMetas, err := ListFileMetadata(cli, nil, masterKey)
+	if err != nil {
+		return nil, err
+	}
+
+	fileMetas := []*FileMetadata{}
+	for _, fileMeta := range allFileMetas {
+		if inDirectory(fileMeta.Directory, directory) {
+			fileMetas = append(fileMetas, fileMeta)
+		}
+	}
+	if len(fileMetas) == 0 {
+		return nil, fmt.Errorf("there are no files in /%s", strings.Join(directory, "/"))
+	}
+	sortFileMetadata(fileMetas)
+
+	return shareFiles(cli, fileMetas, directory, tokenStr, masterKey, opts)
+}
+
+func shareFiles(cli FileServerClient, fileMetas []*FileMetadata, directory []string, tokenStr string, masterKey MasterKey, opts []ShareOption) (*ViewShareInfo, error) {
+	if len(fileMetas) == 0 {
+		return nil, errors.New("can't share no files")
+	}
+	options := shareOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	manifestID := uuid.NewString()
+	manifest := &ShareManifest{
+		Directory: directory,
+	}
+	// the manifest is read with the share's token, so its id is allowed like a file's, but only to be read so a share that can write can't replace it
+	fileIDs := []string{manifestID}
+	for _, fileMeta := range fileMetas {
+		if fileMeta.ContentAddressed {
+			return nil, fmt.Errorf("file %s is content addressed, so it can't be shared", fileMeta.Id)
+		}
+		fileKey, err := fileEncKey(fileMeta.Id, fileMeta.WrappedDataKey, masterKey)
+		if err != nil {
+			return nil, err
+		}
+		manifest.Files = append(manifest.Files, &ShareManifest_SharedFile{
+			Id:         fileMeta.Id,
+			FileEncKey: fileKey,
+		})
+		fileIDs = append(fileIDs, fileMeta.Id)
+	}
+
+	shareKey := make([]byte, chacha20poly1305.KeySize)
+	_, err := rand.Read(shareKey)
+	if err != nil {
+		return nil, err
+	}
+	encryptedManifest, err := encryptShareManifest(manifest, manifestID, shareKey)
+	if err != nil {
+		return nil, err
+	}
+
+	shareToken, err := attenuateShareToken(tokenStr, fileIDs, []string{manifestID}, options)
+	if err != nil {
+		return nil, err
+	}
+	err = uploadShareManifest(cli, manifestID, encryptedManifest)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ViewShareInfo{
+		ManifestId: manifestID,
+		Token:      shareToken,
+		ShareKey:   base64.URLEncoding.EncodeToString(shareKey),
+	}, nil
+}
+
+// inDirectory returns whether a file in fileDirectory is in directory, or a directory below it
+func inDirectory(fileDirectory []string, directory []string) bool {
+	return len(fileDirectory) >= len(directory) && slices.Equal(fileDirectory[:len(directory)], directory)
+}
+
+// sortFileMetadata sorts fileMetas by directory, then name
+func sortFileMetadata(fileMetas []*FileMetadata) {
+	sort.Slice(fileMetas, func(i, j int) bool {
+		if c := slices.Compare(fileMetas[i].Directory, fileMetas[j].Directory); c != 0 {
+			return c < 0
+		}
+		return fileMetas[i].FileName < fileMetas[j].FileName
+	})
+}
+
+// SharedFiles is a share made with ShareFiles or ShareDirectory, opened with OpenShare
+type SharedFiles struct {
+	client FileServerClient
+	codec  *chunkCodec
+	// Directory is the directory that was shared with ShareDirectory, empty for ShareFiles
+	Directory []string
+	fileMetas map[string]*FileMetadata
+}
+
+// OpenShare opens a share from ShareFiles or ShareDirectory, using only what's in view. Files deleted since they were shared are left out
+func OpenShare(cli FileServerClient, view *ViewShareInfo) (*SharedFiles, error) {
+	shareKey, err := base64.URLEncoding.DecodeString(view.ShareKey)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding share key: %w", err)
+	}
+
+	cli = cli.setToken(view.Token)
+	encryptedManifest, err := downloadShareManifest(cli, view.ManifestId)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := decryptShareManifest(encryptedManifest, view.ManifestId, shareKey)
+	if err != nil {
+		return nil, err
+	}
+
+	fileIDs := []string{}
+	fileKeys := map[string][]byte{}
+	for _, sharedFile := range manifest.Files {
+		fileIDs = append(fileIDs, sharedFile.Id)
+		fileKeys[sharedFile.Id] = sharedFile.FileEncKey
+	}
+	if len(fileIDs) == 0 {
+		return nil, errors.New("share manifest has no files")
+	}
+	codec, err := newSharedChunkCodec(fileKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	// one request for all of them, which the token allows since it only asks for the shared files
+	encFileMetas, err := listEncryptedFileMetadata(cli, fileIDs)
+	if err != nil {
+		return nil, err
+	}
+	fileMetas := map[string]*FileMetadata{}
+	for fileID, encFileMeta := range encFileMetas {
+		fileKey, ok := fileKeys[fileID]
+		if !ok || encFileMeta.Id != fileID {
+			return nil, fmt.Errorf("got metadata for file %s, which isn't in the share", encFileMeta.Id)
+		}
+		fileMeta, err := decryptFileMetadataWithFileKey(encFileMeta, fileKey)
+		if err != nil {
+			return nil, fmt.Errorf("error decrypting metadata of file %s: %w", fileID, err)
+		}
+		fileMetas[fileID] = fileMeta
+	}
+
+	return &SharedFiles{
+		client:    cli,
+		codec:     codec,
+		Directory: manifest.Directory,
+		fileMetas: fileMetas,
+	}, nil
+}
+
+// Files returns the metadata of every file in the share, sorted by directory then name
+func (s *SharedFiles) Files() []*FileMetadata {
+	fileMetas := make([]*FileMetadata, 0, len(s.fileMetas))
+	for _, fileMeta := range s.fileMetas {
+		fileMetas = append(fileMetas, fileMeta)
+	}
+	sortFileMetadata(fileMetas)
+	return fileMetas
+}
+
+// Open streams the plaintext of the shared file with fileID like OpenFile
+func (s *SharedFiles) Open(ctx context.Context, fileID string) (io.ReadCloser, error) {
+	fileMeta, err := s.file(fileID)
+	if err != nil {
+		return nil, err
+	}
+	return openFile(ctx, s.client, s.codec, fileMeta), nil
+}
+
+// Download writes the plaintext of the shared file with fileID to fileWriter like DownloadFile
+func (s *SharedFiles) Download(ctx context.Context, fileID string, fileWriter io.Writer) error {
+	fileMeta, err := s.file(fileID)
+	if err != nil {
+		return err
+	}
+	return downloadFile(ctx, s.client, s.codec, fileMeta, fileWriter, "")
+}
+
+func (s *SharedFiles) file(fileID string) (*FileMetadata, error) {
+	fileMeta, ok := s.fileMetas[fileID]
+	if !ok {
+		return nil, fmt.Errorf("file %s isn't in the share", fileID)
+	}
+	return fileMeta, nil
+}
+
+// encryptShareManifest encrypts manifest with shareKey. The manifest id is authenticated too, so the server can't swap one share's manifest for another's
+func encryptShareManifest(manifest *ShareManifest, manifestID string, shareKey []byte) ([]byte, error) {
+	manifestUUID, err := uuid.Parse(manifestID)
+	if err != nil {
+		return nil, err
+	}
+	manifestBin, err := proto.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	enc, err := chacha20poly1305.NewX(shareKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, enc.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return enc.Seal(nonce, nonce, manifestBin, manifestUUID[:]), nil
+}
+
+func decryptShareManifest(encryptedManifest []byte, manifestID string, shareKey []byte) (*ShareManifest, error) {
+	manifestUUID, err := uuid.Parse(manifestID)
+	if err != nil {
+		return nil, err
+	}
+
+	enc, err := chacha20poly1305.NewX(shareKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(encryptedManifest) < enc.NonceSize() {
+		return nil, fmt.Errorf("share manifest is too short")
+	}
+	nonce := encryptedManifest[:enc.NonceSize()]
+	manifestBin, err := enc.Open(nil, nonce, encryptedManifest[enc.NonceSize():], manifestUUID[:])
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest ShareManifest
+	err = proto.Unmarshal(manifestBin, &manifest)
+	if err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+// ListShares returns the manifest ids of every share made with ShareFiles or ShareDirectory that hasn't been deleted with DeleteShare
+func ListShares(cli FileServerClient) ([]string, error) {
+	stored, err := listStoredMetadata(cli, []string{})
+	if err != nil {
+		return nil, err
+	}
+	manifestIDs := []string{}
+	for id, encFileMeta := range stored {
+		if bytes.HasPrefix(encFileMeta.Metadata, []byte(shareManifestMagic)) {
+			manifestIDs = append(manifestIDs, id)
+		}
+	}
+	sort.Strings(manifestIDs)
+	return manifestIDs, nil
+}
+
+// DeleteShare deletes the manifest of a share made with ShareFiles or ShareDirectory, so it can't be opened with OpenShare anymore.
+// its token can still reach the shared files' chunks and metadata until it expires, or is revoked with RevokeShare if it was made WithShareID
+func DeleteShare(cli FileServerClient, manifestID string) error {
+	_, err := downloadShareManifest(cli, manifestID)
+	if err != nil {
+		return err
+	}
+	return DeleteFileMetadata(cli, manifestID)
+}
+
+// uploadShareManifest stores encryptedManifest as file metadata with manifestID, which every server already supports
+func uploadShareManifest(cli FileServerClient, manifestID string, encryptedManifest []byte) error {
+	return uploadEncryptedFileMetadata(cli, &EncryptedFileMetadata{
+		Id:       manifestID,
+		Metadata: append([]byte(shareManifestMagic), encryptedManifest...),
+	})
+}
+
+func downloadShareManifest(cli FileServerClient, manifestID string) ([]byte, error) {
+	encFileMeta, err := downloadEncryptedFileMetadata(cli, manifestID)
+	if err != nil {
+		return nil, fmt.Errorf("error downloading share manifest: %w", err)
+	}
+	encryptedManifest, ok := bytes.CutPrefix(encFileMeta.Metadata, []byte(shareManifestMagic))
+	if !ok || encFileMeta.Id != manifestID {
+		return nil, fmt.Errorf("%s isn't a share manifest", manifestID)
+	}
+	return encryptedManifest, nil
+}
@@ -0,0 +1,168 @@
+package bfsp_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"io"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/BillysBigFileServer/bfsp-go"
+	"github.com/BillysBigFileServer/bfsp-go/bfsptest"
+)
+
+// newTestContext returns a context with a client for a new bfsptest server and a random master key
+func newTestContext(t *testing.T) (context.Context, *bfsptest.Server, bfsp.MasterKey) {
+	t.Helper()
+	srv := bfsptest.NewServer()
+	masterKey := make(bfsp.MasterKey, 32)
+	_, err := rand.Read(masterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := bfsp.ContextWithMasterKey(bfsp.ContextWithClient(context.Background(), srv.Client()), masterKey)
+	return ctx, srv, masterKey
+}
+
+func randomBytes(t *testing.T, size int) []byte {
+	t.Helper()
+	b := make([]byte, size)
+	_, err := rand.Read(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestUploadFileDoesNotRetryServerErrors(t *testing.T) {
+	ctx, srv, _ := newTestContext(t)
+	srv.StorageCap = bfsp.ChunkSize
+
+	start := time.Now()
+	_, err := bfsp.UploadFile(ctx, &bfsp.FileInfo{Name: "file", Reader: bytes.NewReader(randomBytes(t, 3*bfsp.ChunkSize))}, 1)
+	if err == nil {
+		t.Fatal("expected the upload to go over the storage cap")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("upload took %s to fail, the storage cap error was probably retried", elapsed)
+	}
+}
+
+func TestUploadDownloadRoundTrip(t *testing.T) {
+	sizes := map[string]int{
+		"empty":          0,
+		"one byte":       1,
+		"chunk size - 1": bfsp.ChunkSize - 1,
+		"chunk size":     bfsp.ChunkSize,
+		"chunk size + 1": bfsp.ChunkSize + 1,
+		"multi chunk":    3*bfsp.ChunkSize + 7,
+	}
+	readers := map[string]func(io.Reader) io.Reader{
+		"reader":          func(r io.Reader) io.Reader { return r },
+		"one byte reader": iotest.OneByteReader,
+		"half reader":     iotest.HalfReader,
+	}
+	chunkings := map[string][]bfsp.UploadOption{
+		"fixed":                  nil,
+		"content defined":        {bfsp.WithContentDefinedChunking()},
+		"fixed padded":           {bfsp.WithPadding()},
+		"content defined padded": {bfsp.WithContentDefinedChunking(), bfsp.WithPadding()},
+	}
+
+	for sizeName, size := range sizes {
+		for readerName, reader := range readers {
+			for chunkingName, opts := range chunkings {
+				t.Run(sizeName+"/"+readerName+"/"+chunkingName, func(t *testing.T) {
+					t.Parallel()
+					ctx, _, _ := newTestContext(t)
+					data := randomBytes(t, size)
+
+					res, err := bfsp.UploadFile(ctx, &bfsp.FileInfo{Name: "file", Reader: reader(bytes.NewReader(data))}, 4, opts...)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if res.FileMetadata.FileSize != uint64(size) {
+						t.Fatalf("file size is %d, expected %d", res.FileMetadata.FileSize, size)
+					}
+
+					var downloaded bytes.Buffer
+					err = bfsp.DownloadFile(ctx, res.FileMetadata, &downloaded, "")
+					if err != nil {
+						t.Fatal(err)
+					}
+					if !bytes.Equal(downloaded.Bytes(), data) {
+						t.Fatalf("downloaded %d bytes that don't match the %d uploaded", downloaded.Len(), size)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestUploadFileChunkIndices(t *testing.T) {
+	ctx, _, _ := newTestContext(t)
+	data := randomBytes(t, 3*bfsp.ChunkSize+7)
+
+	// short reads must still fill every chunk, so a file splits into the same chunks whatever the reader
+	res, err := bfsp.UploadFile(ctx, &bfsp.FileInfo{Name: "file", Reader: iotest.HalfReader(bytes.NewReader(data))}, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.FileMetadata.Chunks) != 4 {
+		t.Fatalf("file has %d chunks, expected 4", len(res.FileMetadata.Chunks))
+	}
+	for indice := uint64(0); indice < 4; indice++ {
+		if _, ok := res.FileMetadata.Chunks[indice]; !ok {
+			t.Fatalf("file has no chunk %d: %v", indice, res.FileMetadata.Chunks)
+		}
+	}
+}
+
+func TestUploadFileAbort(t *testing.T) {
+	chunkings := map[string]struct {
+		opts []bfsp.UploadOption
+		// content addressed chunks may be shared with another upload, so they're left on the server
+		keepsChunks bool
+	}{
+		"fixed":           {nil, false},
+		"content defined": {[]bfsp.UploadOption{bfsp.WithContentDefinedChunking()}, true},
+	}
+	for name, chunking := range chunkings {
+		t.Run(name, func(t *testing.T) {
+			ctx, srv, _ := newTestContext(t)
+			srv.StorageCap = 5 * bfsp.ChunkSize / 2
+
+			_, err := bfsp.UploadFile(ctx, &bfsp.FileInfo{Name: "file", Reader: bytes.NewReader(randomBytes(t, 8*bfsp.ChunkSize))}, 1, chunking.opts...)
+			if err == nil {
+				t.Fatal("expected the upload to go over the storage cap")
+			}
+			if chunkCount := srv.ChunkCount(); (chunkCount > 0) != chunking.keepsChunks {
+				t.Fatalf("%d chunks are left after the upload failed", chunkCount)
+			}
+		})
+	}
+}
+
+func TestUploadFileDeduplicates(t *testing.T) {
+	ctx, srv, _ := newTestContext(t)
+	data := randomBytes(t, 8*bfsp.ChunkSize)
+
+	_, err := bfsp.UploadFile(ctx, &bfsp.FileInfo{Name: "file", Reader: bytes.NewReader(data)}, 4, bfsp.WithContentDefinedChunking())
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunkCount := srv.ChunkCount()
+
+	res, err := bfsp.UploadFile(ctx, &bfsp.FileInfo{Name: "copy", Reader: bytes.NewReader(data)}, 4, bfsp.WithContentDefinedChunking())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.DeduplicatedBytes != uint64(len(data)) {
+		t.Fatalf("deduplicated %d bytes, expected %d", res.DeduplicatedBytes, len(data))
+	}
+	if srv.ChunkCount() != chunkCount {
+		t.Fatalf("the copy uploaded %d more chunks", srv.ChunkCount()-chunkCount)
+	}
+}